@@ -3,6 +3,9 @@ package analyser
 
 import (
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 
 	"github.com/saicheems/simplelang/ast"
 	"github.com/saicheems/simplelang/parser"
@@ -12,18 +15,32 @@ import (
 
 // Analyser implements the semantic analysis stage of the compilation.
 type Analyser struct {
-	par *parser.Parser
-	err []error
+	par       *parser.Parser
+	modPath   []string // Directories searched for the interface files of imported modules.
+	coroutine bool     // Whether or not the statements being checked are inside a coroutine.
+	// Declarations of the types that haven't been resolved yet, by the Value of the declared name.
+	typeDecls map[*symtable.Value]*ast.Node
+	resolving map[*symtable.Value]bool // Declared types that are being resolved.
+	err       []error
 }
 
 // New returns a new Analyser.
 func New(par *parser.Parser) *Analyser {
 	a := new(Analyser)
 	a.par = par
+	a.modPath = []string{"."}
+	a.typeDecls = make(map[*symtable.Value]*ast.Node)
+	a.resolving = make(map[*symtable.Value]bool)
 	a.err = make([]error, 0)
 	return a
 }
 
+// SetModulePath sets the directories searched in order for the interface files of imported
+// modules. It should be called before Analyse. The default is the current directory.
+func (a *Analyser) SetModulePath(dirs []string) {
+	a.modPath = dirs
+}
+
 // Analyse returns the abstract syntax tree if the semantic analysis is successful. Otherwise it
 // returns nil.
 func (a *Analyser) Analyse() *ast.Node {
@@ -32,7 +49,9 @@ func (a *Analyser) Analyse() *ast.Node {
 		return nil
 	}
 	a.loadSymbolTables(ast.Children[0])
+	a.importCheck(ast.Children[1], ast.Children[0].Sym)
 	a.recurseProgramCheck(ast)
+	a.exportCheck(ast.Children[2], ast.Children[0].Sym)
 	if len(a.err) > 0 {
 		for _, err := range a.err {
 			fmt.Println(err)
@@ -47,21 +66,80 @@ func (a *Analyser) Analyse() *ast.Node {
 func (a *Analyser) loadSymbolTables(node *ast.Node) {
 	sym := symtable.New()
 	cons := node.Children[0] // Constants
-	vars := node.Children[1] // Vars
-	proc := node.Children[2] // Procedures
+	typs := node.Children[1] // Types
+	vars := node.Children[2] // Vars
+	stat := node.Children[3] // Statics
+	proc := node.Children[4] // Procedures
 
 	for _, node := range cons.Children {
 		iden := node.Children[0]
-		val := node.Children[1].Tok.Val
-		sym.Put(symtable.Key{symtable.Constant, iden.Tok.Lex}, &symtable.Value{Val: val})
+		tok := node.Children[1].Tok
+		value := &symtable.Value{Val: tok.Val, Type: symtable.IntegerType}
+		if tok.Tag == token.Real {
+			// Real constants are kept as the bits of a single precision float.
+			value.Val = int(int32(math.Float32bits(float32(tok.Rval))))
+			value.Type = symtable.RealType
+		}
+		sym.Put(symtable.Key{symtable.Constant, iden.Tok.Lex}, value)
+	}
+	for _, node := range typs.Children {
+		iden := node.Children[0]
+		enum := node.Children[1]
+		if sym.Get(symtable.Key{symtable.TypeName, iden.Tok.Lex}) != nil {
+			a.appendError(iden.Tok)
+		}
+		if enum.Tag != ast.Enum {
+			// Other types can name types declared after them, so they're resolved once every
+			// name is in the symbol table.
+			value := &symtable.Value{}
+			sym.Put(symtable.Key{symtable.TypeName, iden.Tok.Lex}, value)
+			a.typeDecls[value] = enum
+			continue
+		}
+		typ := &symtable.Type{Kind: symtable.EnumKind, Name: iden.Tok.Lex}
+		sym.Put(symtable.Key{symtable.TypeName, iden.Tok.Lex}, &symtable.Value{Type: typ})
+		// The values of an enumeration are constants holding their position in the list.
+		for i, node := range enum.Children {
+			key := symtable.Key{symtable.Constant, node.Tok.Lex}
+			if sym.Get(key) != nil {
+				a.appendError(node.Tok)
+			}
+			sym.Put(key, &symtable.Value{Val: i, Type: typ})
+			typ.Values = append(typ.Values, node.Tok.Lex)
+		}
 	}
 	for i, node := range vars.Children {
+		// The type is filled in when the var node is checked.
 		sym.Put(symtable.Key{symtable.Integer, node.Tok.Lex}, &symtable.Value{Order: i})
 	}
+	for _, node := range stat.Children {
+		// Statics are variables that aren't kept in the activation record. They get a label in
+		// the data segment during code generation.
+		key := symtable.Key{symtable.Integer, node.Tok.Lex}
+		if sym.Get(key) != nil {
+			a.appendError(node.Tok)
+		}
+		sym.Put(key, &symtable.Value{})
+	}
 	for _, node := range proc.Children {
 		iden := node.Children[0]
+		// Procedures and coroutines share a name space, and each name is declared once.
+		tag, other := symtable.Procedure, symtable.Coroutine
+		if node.Tag == ast.Coroutine {
+			tag, other = other, tag
+		}
+		if sym.Get(symtable.Key{other, iden.Tok.Lex}) != nil ||
+			sym.Get(symtable.Key{tag, iden.Tok.Lex}) != nil {
+			a.appendError(iden.Tok)
+		}
+		if node.Tag == ast.External {
+			// An external procedure is labelled with its own name.
+			sym.Put(symtable.Key{tag, iden.Tok.Lex}, &symtable.Value{Label: iden.Tok.Lex,
+				External: true, NumArgs: len(node.Children) - 1})
+			continue
+		}
 		bloc := node.Children[1]
-		sym.Put(symtable.Key{symtable.Procedure, iden.Tok.Lex}, symtable.EmptyValue)
+		sym.Put(symtable.Key{tag, iden.Tok.Lex}, symtable.EmptyValue)
 		// Recursively load on inner procedures.
 		a.loadSymbolTables(bloc)
 
@@ -69,6 +147,82 @@ func (a *Analyser) loadSymbolTables(node *ast.Node) {
 	node.Sym = sym
 }
 
+// importCheck loads the interface file of every imported module into the symbol table of the
+// program. Imported symbols can't have the same name as anything declared at the top level of the
+// program or imported from another module.
+func (a *Analyser) importCheck(node *ast.Node, sym *symtable.SymbolTable) {
+	for _, iden := range node.Children {
+		imp := a.loadInterface(iden.Tok.Lex)
+		if imp == nil {
+			a.appendError(iden.Tok)
+			continue
+		}
+		for _, key := range imp.Keys() {
+			if a.isDeclared(key.Lex, sym) {
+				a.appendError(iden.Tok)
+			}
+			sym.Put(key, imp.Get(key))
+		}
+	}
+}
+
+// loadInterface searches the module path for the interface file of a module and returns a symbol
+// table with its contents. It returns nil if the interface file can't be found or read.
+func (a *Analyser) loadInterface(name string) *symtable.SymbolTable {
+	for _, dir := range a.modPath {
+		f, err := os.Open(filepath.Join(dir, name+".int"))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		sym, err := symtable.ReadInterface(f)
+		if err != nil {
+			return nil
+		}
+		return sym
+	}
+	return nil
+}
+
+// isDeclared returns a bool representing whether or not anything is declared with a name in the
+// symbol table.
+func (a *Analyser) isDeclared(lex string, sym *symtable.SymbolTable) bool {
+	for _, tag := range []int{symtable.Constant, symtable.Integer, symtable.Procedure,
+		symtable.TypeName, symtable.Coroutine} {
+		if sym.Get(symtable.Key{tag, lex}) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// exportCheck validates the names exported by a module. They must be constants, variables or
+// procedures declared at the top level of the module (or imported into it). Constants and variables
+// must have a type that can be written to an interface file, and external procedures can't be
+// written to one at all.
+func (a *Analyser) exportCheck(node *ast.Node, sym *symtable.SymbolTable) {
+	for _, iden := range node.Children {
+		found := false
+		for _, tag := range []int{symtable.Constant, symtable.Integer} {
+			if value := sym.Get(symtable.Key{tag, iden.Tok.Lex}); value != nil {
+				found = true
+				if !symtable.Exportable(value.Type) {
+					a.appendError(iden.Tok)
+				}
+			}
+		}
+		if value := sym.Get(symtable.Key{symtable.Procedure, iden.Tok.Lex}); value != nil {
+			found = true
+			if value.External {
+				a.appendError(iden.Tok)
+			}
+		}
+		if !found {
+			a.appendError(iden.Tok)
+		}
+	}
+}
+
 // recurseProgramCheck recurses on the top node in the AST (the program node).
 func (a *Analyser) recurseProgramCheck(node *ast.Node) {
 	a.recurseBlockCheck(node.Children[0], make([]*symtable.SymbolTable, 0))
@@ -80,26 +234,123 @@ func (a *Analyser) recurseConstCheck(node *ast.Node, syms []*symtable.SymbolTabl
 	// anyway.
 }
 
-// recurseVarCheck recurses on the var node.
+// recurseTypeDeclCheck recurses on the type declaration node. It sets the type named by each
+// declaration in the symbol table.
+func (a *Analyser) recurseTypeDeclCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	for _, node := range node.Children {
+		iden := node.Children[0]
+		a.resolveType(syms[len(syms)-1].Get(symtable.Key{symtable.TypeName, iden.Tok.Lex}), syms)
+	}
+}
+
+// resolveType sets the type named by a declaration if it hasn't been resolved yet. A declaration
+// can name types declared after it in the same block, but a type can't be declared in terms of
+// itself, either directly or through other declarations.
+func (a *Analyser) resolveType(value *symtable.Value, syms []*symtable.SymbolTable) {
+	node := a.typeDecls[value]
+	if node == nil {
+		return
+	}
+	delete(a.typeDecls, value)
+	a.resolving[value] = true
+	value.Type = a.typeCheck(node, syms)
+	delete(a.resolving, value)
+}
+
+// recurseVarCheck recurses on the var or static node. It sets the type of each var in the symbol
+// table, along with its position in the stack frame since arrays take up more than one word.
 func (a *Analyser) recurseVarCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	words := 0 // Words taken up by the vars before this one.
 	// If the immediate parent symbol table has constants of the same name, then there's an
 	// ambiguity issue.
 	for _, node := range node.Children {
 		if a.findSymbolInTables(node.Tok.Lex, symtable.Constant, syms) {
 			a.appendError(node.Tok)
 		}
+		value := syms[len(syms)-1].Get(symtable.Key{symtable.Integer, node.Tok.Lex})
+		value.Type = symtable.IntegerType
+		if len(node.Children) > 0 {
+			value.Type = a.typeCheck(node.Children[0], syms)
+		}
+		// Elements are addressed upwards from the lowest address of the array.
+		value.Order = words + value.Type.Size() - 1
+		words += value.Type.Size()
 	}
 }
 
-// recurseProcedureCheck recurses on the procedure node.
+// typeCheck validates a type node and returns the type it names. The type is nil if it names a type
+// that hasn't been declared or is declared in terms of itself. The bounds of a set must fit in a
+// word and an array must have at least one element.
+func (a *Analyser) typeCheck(node *ast.Node, syms []*symtable.SymbolTable) *symtable.Type {
+	if node.Tok.Tag == token.RealType {
+		return symtable.RealType
+	} else if node.Tok.Tag == token.Semaphore {
+		return symtable.SemaphoreType
+	} else if node.Tok.Tag == token.File {
+		return symtable.FileType
+	} else if node.Tok.Tag == token.StringType {
+		return symtable.StringType
+	} else if node.Tok.Tag == token.CharType {
+		return symtable.CharType
+	} else if node.Tok.Tag == token.Set {
+		low := node.Children[0].Tok
+		high := node.Children[1].Tok
+		if low.Val > high.Val || high.Val > 31 {
+			a.appendError(high)
+		}
+		return symtable.SetType
+	} else if node.Tok.Tag == token.Array {
+		length := node.Children[0].Tok
+		elem := a.typeCheck(node.Children[1], syms)
+		if length.Val < 1 {
+			a.appendError(length)
+			return nil
+		}
+		if elem == nil {
+			return nil
+		}
+		return &symtable.Type{Kind: symtable.ArrayKind, Name: fmt.Sprintf("ARRAY %d OF %s",
+			length.Val, elem.Name), Len: length.Val, Elem: elem}
+	} else if node.Tok.Tag == token.Identifier {
+		value := a.lookupSymbolInTables(node.Tok.Lex, symtable.TypeName, syms)
+		if value == nil || a.resolving[value] {
+			a.appendError(node.Tok)
+			return nil
+		}
+		a.resolveType(value, syms)
+		return value.Type
+	}
+	return symtable.IntegerType
+}
+
+// recurseProcedureCheck recurses on the procedure node. Coroutines keep their state in the data
+// segment, so they can only be declared at the top level where there's a single activation of the
+// enclosing block. Procedures nested in a coroutine are inside it too.
 func (a *Analyser) recurseProcedureCheck(node *ast.Node, syms []*symtable.SymbolTable) {
 	for _, node := range node.Children {
 		id := node.Children[0]
-		if !a.findSymbolInTables(id.Tok.Lex, symtable.Procedure, syms) {
+		tag := symtable.Procedure
+		if node.Tag == ast.Coroutine {
+			tag = symtable.Coroutine
+			if len(syms) > 1 {
+				a.appendError(id.Tok)
+			}
+		}
+		if !a.findSymbolInTables(id.Tok.Lex, tag, syms) {
 			a.appendError(id.Tok)
 		}
+		if node.Tag == ast.External {
+			// There are only four argument registers.
+			if len(node.Children) > 5 {
+				a.appendError(node.Children[5].Tok)
+			}
+			continue
+		}
 		bloc := node.Children[1]
+		coroutine := a.coroutine
+		a.coroutine = coroutine || node.Tag == ast.Coroutine
 		a.recurseBlockCheck(bloc, syms)
+		a.coroutine = coroutine
 	}
 }
 
@@ -107,14 +358,16 @@ func (a *Analyser) recurseProcedureCheck(node *ast.Node, syms []*symtable.Symbol
 func (a *Analyser) recurseBlockCheck(node *ast.Node, syms []*symtable.SymbolTable) {
 	syms = append(syms, node.Sym)
 	a.recurseConstCheck(node.Children[0], syms)
-	a.recurseVarCheck(node.Children[1], syms)
-	a.recurseProcedureCheck(node.Children[2], syms)
-	a.recurseStatementCheck(node.Children[3], syms)
+	a.recurseTypeDeclCheck(node.Children[1], syms)
+	a.recurseVarCheck(node.Children[2], syms)
+	a.recurseVarCheck(node.Children[3], syms)
+	a.recurseProcedureCheck(node.Children[4], syms)
+	a.recurseStatementCheck(node.Children[5], syms)
 }
 
 // recurseStatementCheck recurses on a statement.
 func (a *Analyser) recurseStatementCheck(node *ast.Node, syms []*symtable.SymbolTable) {
-	if node.Tag == ast.Assignment {
+	if node.Tag == ast.Assignment || node.Tag == ast.CompoundAssignment {
 		a.assignmentCheck(node, syms)
 	} else if node.Tag == ast.Call {
 		a.callCheck(node, syms)
@@ -127,28 +380,299 @@ func (a *Analyser) recurseStatementCheck(node *ast.Node, syms []*symtable.Symbol
 	} else if node.Tag == ast.WhileDo {
 		a.whileDoCheck(node, syms)
 	} else if node.Tag == ast.Print {
-		a.recurseExpressionCheck(node.Children[0], syms)
+		typ := a.recurseExpressionCheck(node.Children[0], syms)
+		if typ != nil && typ != symtable.IntegerType && typ != symtable.RealType {
+			a.appendError(node.Children[0].Tok)
+		}
+	} else if node.Tag == ast.Write {
+		a.writeCheck(node, syms)
+	} else if node.Tag == ast.Assert {
+		a.assertCheck(node, syms)
+	} else if node.Tag == ast.For {
+		a.forCheck(node, syms)
+	} else if node.Tag == ast.Case {
+		a.caseCheck(node, syms)
+	} else if node.Tag == ast.Try {
+		a.tryCheck(node, syms)
+	} else if node.Tag == ast.Raise {
+		code := node.Children[0]
+		a.expectType(code, a.recurseExpressionCheck(code, syms), symtable.IntegerType)
+	} else if node.Tag == ast.Halt {
+		if len(node.Children) > 0 {
+			code := node.Children[0]
+			a.expectType(code, a.recurseExpressionCheck(code, syms), symtable.IntegerType)
+		}
+	} else if node.Tag == ast.Open || node.Tag == ast.Close || node.Tag == ast.Read {
+		a.fileCheck(node, syms)
+	} else if node.Tag == ast.Asm {
+		// A line of assembly can refer to any variable in scope.
+		for _, line := range node.Children {
+			for _, iden := range line.Children {
+				a.assignableCheck(iden, syms)
+			}
+		}
+	} else if node.Tag == ast.Cobegin {
+		a.cobeginCheck(node, syms)
+	} else if node.Tag == ast.Wait || node.Tag == ast.Signal {
+		iden := node.Children[0]
+		a.expectType(iden, a.assignableCheck(iden, syms), symtable.SemaphoreType)
+	} else if node.Tag == ast.Yield {
+		// Only a coroutine (or a procedure in one) has somewhere to yield to.
+		if !a.coroutine {
+			a.appendError(node.Tok)
+		}
+		expr := node.Children[0]
+		a.expectType(expr, a.recurseExpressionCheck(expr, syms), symtable.IntegerType)
 	} else {
 		// This shouldn't happen ever...
 		a.appendError(node.Tok)
 	}
 }
 
-// assignmentCheck validates an assigment.
+// assignmentCheck validates an assigment. Compound assignments (+=, -=, *=, INC and DEC) are held
+// to the same rules since they also store to the left hand side. INC and DEC only work on integers
+// and the other compound assignments don't work on enumerations. The characters of a string can't
+// be assigned to.
 func (a *Analyser) assignmentCheck(node *ast.Node, syms []*symtable.SymbolTable) {
 	iden := node.Children[0]
 	expr := node.Children[1]
 	a.recurseExpressionCheck(expr, syms)
-	if !a.findSymbolInTables(iden.Tok.Lex, symtable.Integer, syms) {
+	typ := a.assignableCheck(iden, syms)
+	if iden.Tag == ast.Index && iden.Children[0].Type == symtable.StringType {
+		a.appendError(iden.Tok)
+		return
+	}
+	if node.Tag == ast.CompoundAssignment && (node.Tok.Tag == token.Inc ||
+		node.Tok.Tag == token.Dec) {
+		a.expectType(iden, typ, symtable.IntegerType)
+		a.expectType(expr, expr.Type, symtable.IntegerType)
+		return
+	}
+	if node.Tag == ast.CompoundAssignment && !a.isArithmetic(typ, node.Op) {
+		a.appendError(node.Tok)
+	}
+	node.Children[1] = a.convertCheck(typ, expr)
+}
+
+// assignableCheck validates that a terminal node names a variable that can be assigned to, or that
+// an index node is an element of one, and returns the type of the variable or element. The type is
+// nil if it can't be assigned to.
+func (a *Analyser) assignableCheck(iden *ast.Node, syms []*symtable.SymbolTable) *symtable.Type {
+	if iden.Tag == ast.Index {
+		iden.Type = a.indexCheck(iden, a.assignableCheck(iden.Children[0], syms), syms)
+		return iden.Type
+	}
+	value := a.lookupSymbolInTables(iden.Tok.Lex, symtable.Integer, syms)
+	if value == nil {
 		a.appendError(iden.Tok)
+		return nil
+	}
+	iden.Type = value.Type
+	return value.Type
+}
+
+// indexCheck validates an index node given the type of the array or string it indexes and returns
+// the type of the element, which is a CHAR for a string. The index must be an INTEGER, and a
+// constant index must be within the array, which starts at 0.
+func (a *Analyser) indexCheck(node *ast.Node, typ *symtable.Type,
+	syms []*symtable.SymbolTable) *symtable.Type {
+	index := node.Children[1]
+	a.expectType(index, a.recurseExpressionCheck(index, syms), symtable.IntegerType)
+	if typ == nil {
+		return nil
+	}
+	if typ == symtable.StringType {
+		return symtable.CharType
+	}
+	if !isArray(typ) {
+		a.appendError(node.Tok)
+		return nil
+	}
+	if val, ok := a.constantValue(index, syms); ok && (val < 0 || val >= typ.Len) {
+		a.appendError(index.Tok)
+	}
+	return typ.Elem
+}
+
+// convertCheck validates that an expression can be stored in a variable of the given type and
+// returns the expression to store. An INTEGER expression stored in a REAL is converted with FLOAT
+// and a string of one character stored in a CHAR is converted to the character.
+func (a *Analyser) convertCheck(typ *symtable.Type, expr *ast.Node) *ast.Node {
+	if typ == symtable.RealType && expr.Type == symtable.IntegerType {
+		return a.convertToReal(expr)
+	}
+	if typ == symtable.CharType {
+		if char := a.convertToChar(expr); char != nil {
+			return char
+		}
+	}
+	if typ == symtable.SemaphoreType && expr.Type == symtable.IntegerType {
+		// Assigning to a semaphore sets its count.
+		return expr
+	}
+	a.expectType(expr, expr.Type, typ)
+	return expr
+}
+
+// convertToReal wraps an INTEGER expression in a FLOAT function node.
+func (a *Analyser) convertToReal(expr *ast.Node) *ast.Node {
+	tok := &token.Token{Tag: token.Float, Ln: expr.Tok.Ln, File: expr.Tok.File}
+	node := ast.NewFunctionNode(tok, expr)
+	node.Type = symtable.RealType
+	return node
+}
+
+// convertToChar returns a CHAR terminal node holding the character of a string literal with a
+// single character. It returns nil if the node isn't one.
+func (a *Analyser) convertToChar(expr *ast.Node) *ast.Node {
+	if expr.Tag != ast.Terminal || expr.Tok.Tag != token.String || len(expr.Tok.Lex) != 1 {
+		return nil
+	}
+	tok := token.New(expr.Tok.Ln, expr.Tok.File)
+	tok.Tag = token.Integer
+	tok.Val = int(expr.Tok.Lex[0])
+	node := ast.NewTerminalNode(tok)
+	node.Type = symtable.CharType
+	return node
+}
+
+// expectType appends an error at the node if the type isn't equivalent to the expected type. Types
+// that are nil have already caused an error, so they're ignored.
+func (a *Analyser) expectType(node *ast.Node, typ *symtable.Type, expect *symtable.Type) {
+	if typ != nil && expect != nil && !symtable.Equivalent(typ, expect) {
+		a.appendError(node.Tok)
 	}
 }
 
-// callCheck validates a call.
-func (a *Analyser) callCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+// callCheck validates a call and returns the Value of the procedure, or nil if there is no such
+// procedure.
+func (a *Analyser) callCheck(node *ast.Node, syms []*symtable.SymbolTable) *symtable.Value {
 	iden := node.Children[0]
-	if !a.findSymbolInTables(iden.Tok.Lex, symtable.Procedure, syms) {
+	args := node.Children[1:]
+	value := a.lookupSymbolInTables(iden.Tok.Lex, symtable.Procedure, syms)
+	if value == nil {
 		a.appendError(iden.Tok)
+		return nil
+	}
+	// Only external procedures take arguments, which are INTEGERs.
+	if len(args) != value.NumArgs {
+		a.appendError(iden.Tok)
+	}
+	for _, arg := range args {
+		a.expectType(arg, a.recurseExpressionCheck(arg, syms), symtable.IntegerType)
+	}
+	return value
+}
+
+// writeCheck validates a write statement. Strings don't need any checking, but the expressions and
+// widths do. Widths must be integers and can't be used with reals or characters, which can't be
+// written to a file.
+func (a *Analyser) writeCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	file := a.writeFileCheck(node, syms)
+	items := node.Children
+	if file {
+		items = items[1:]
+	}
+	for _, node := range items {
+		item := node.Children[0]
+		if item.Tag != ast.Terminal || item.Tok.Tag != token.String {
+			typ := a.recurseExpressionCheck(item, syms)
+			if typ != nil && typ != symtable.IntegerType && typ != symtable.RealType &&
+				typ != symtable.StringType && typ != symtable.CharType {
+				a.appendError(item.Tok)
+			}
+			if file && (typ == symtable.RealType || typ == symtable.CharType) {
+				a.appendError(item.Tok)
+			}
+		}
+		if len(node.Children) > 1 {
+			width := node.Children[1]
+			a.expectType(width, a.recurseExpressionCheck(width, syms), symtable.IntegerType)
+			if item.Type == symtable.RealType || item.Type == symtable.CharType {
+				a.appendError(width.Tok)
+			}
+		}
+	}
+}
+
+// writeFileCheck returns whether a write statement writes to a file. It does if its first item is a
+// FILE variable without a width, in which case the item is replaced with the terminal Node naming
+// the file for the generator.
+func (a *Analyser) writeFileCheck(node *ast.Node, syms []*symtable.SymbolTable) bool {
+	if len(node.Children) == 0 || len(node.Children[0].Children) > 1 {
+		return false
+	}
+	item := node.Children[0].Children[0]
+	if item.Tag != ast.Terminal || item.Tok.Tag != token.Identifier {
+		return false
+	}
+	value := a.lookupSymbolInTables(item.Tok.Lex, symtable.Integer, syms)
+	if value == nil || value.Type != symtable.FileType {
+		return false
+	}
+	item.Type = value.Type
+	node.Children[0] = item
+	return true
+}
+
+// fileCheck validates an open, close or read statement. Each of them names a FILE variable. OPEN
+// takes an INTEGER mode and READ reads into INTEGER variables.
+func (a *Analyser) fileCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	a.expectType(iden, a.assignableCheck(iden, syms), symtable.FileType)
+	if node.Tag == ast.Open && len(node.Children) > 2 {
+		mode := node.Children[2]
+		a.expectType(mode, a.recurseExpressionCheck(mode, syms), symtable.IntegerType)
+	}
+	if node.Tag == ast.Read {
+		for _, iden := range node.Children[1:] {
+			a.expectType(iden, a.assignableCheck(iden, syms), symtable.IntegerType)
+		}
+	}
+}
+
+// assertCheck validates an assert statement.
+func (a *Analyser) assertCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	a.recurseConditionCheck(node.Children[0], syms)
+	if len(node.Children) > 1 {
+		code := node.Children[1]
+		a.expectType(code, a.recurseExpressionCheck(code, syms), symtable.IntegerType)
+	}
+}
+
+// tryCheck validates a try statement. The code of each handler must be an INTEGER constant and no
+// two handlers can catch the same code. Each code is replaced with its value for the generator.
+func (a *Analyser) tryCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	a.recurseStatementCheck(node.Children[0], syms)
+	seen := make(map[int]bool)
+	for _, handler := range node.Children[1:] {
+		code := handler.Children[0]
+		typ := a.recurseExpressionCheck(code, syms)
+		val, ok := a.constantValue(code, syms)
+		if !ok || typ != symtable.IntegerType || seen[val] {
+			a.appendError(code.Tok)
+		} else {
+			seen[val] = true
+			tok := token.New(code.Tok.Ln, code.Tok.File)
+			tok.Tag = token.Integer
+			tok.Val = val
+			handler.Children[0] = ast.NewTerminalNode(tok)
+		}
+		a.recurseStatementCheck(handler.Children[1], syms)
+	}
+}
+
+// cobeginCheck validates a cobegin statement. The processes are run by a scheduler that returns to
+// the main program when they're done, so a cobegin can only be part of the main statement.
+func (a *Analyser) cobeginCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	if len(syms) > 1 {
+		a.appendError(node.Tok)
+	}
+	for _, call := range node.Children {
+		// An external procedure can't run as a process.
+		if value := a.callCheck(call, syms); value != nil && value.External {
+			a.appendError(call.Children[0].Tok)
+		}
 	}
 }
 
@@ -164,49 +688,347 @@ func (a *Analyser) whileDoCheck(node *ast.Node, syms []*symtable.SymbolTable) {
 	a.recurseStatementCheck(node.Children[1], syms)
 }
 
-// recurseExpressionCheck recurses on an expression.
-func (a *Analyser) recurseExpressionCheck(node *ast.Node, syms []*symtable.SymbolTable) {
-	if node.Tag == ast.Terminal {
-		// Only look through the symbol table if it's an idenfitier!
-		if node.Tok.Tag == token.Identifier {
-			if !a.findSymbolInTables(node.Tok.Lex, symtable.Integer, syms) &&
-				!a.findSymbolInTables(node.Tok.Lex, symtable.Constant, syms) {
-				a.appendError(node.Tok)
+// forCheck validates a for statement. The control variable must be an INTEGER or an enumeration,
+// and the limit must have the same type as the variable.
+func (a *Analyser) forCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	init := node.Children[0]
+	limit := node.Children[1]
+	a.assignmentCheck(init, syms)
+	typ := init.Children[0].Type
+	if typ != nil && typ != symtable.IntegerType && typ.Kind != symtable.EnumKind {
+		a.appendError(init.Children[0].Tok)
+	}
+	a.expectType(limit, a.recurseExpressionCheck(limit, syms), typ)
+	a.recurseStatementCheck(node.Children[2], syms)
+}
+
+// caseCheck validates a case statement. The selector must be an INTEGER or an enumeration, and the
+// labels of the arms must be constants of the same type. Each label is replaced by an integer
+// terminal node holding its value, and a value can only label one arm.
+func (a *Analyser) caseCheck(node *ast.Node, syms []*symtable.SymbolTable) {
+	expr := node.Children[0]
+	typ := a.recurseExpressionCheck(expr, syms)
+	if typ != nil && typ != symtable.IntegerType && typ.Kind != symtable.EnumKind {
+		a.appendError(node.Tok)
+	}
+	seen := make(map[int]bool)
+	for _, arm := range node.Children[1:] {
+		for i, label := range arm.Children[1:] {
+			a.expectType(label, a.recurseExpressionCheck(label, syms), typ)
+			val, ok := a.constantValue(label, syms)
+			if !ok || seen[val] {
+				a.appendError(label.Tok)
+				continue
 			}
+			seen[val] = true
+			tok := token.New(label.Tok.Ln, label.Tok.File)
+			tok.Tag = token.Integer
+			tok.Val = val
+			arm.Children[i+1] = ast.NewTerminalNode(tok)
 		}
-		return
+		a.recurseStatementCheck(arm.Children[0], syms)
+	}
+}
+
+// recurseExpressionCheck recurses on an expression. It sets and returns the type of the expression.
+// The type is nil if there's an error in the expression.
+func (a *Analyser) recurseExpressionCheck(node *ast.Node,
+	syms []*symtable.SymbolTable) *symtable.Type {
+	if node.Tag == ast.Terminal {
+		node.Type = a.terminalCheck(node, syms)
+		return node.Type
+	} else if node.Tag == ast.Function {
+		node.Type = a.functionCheck(node, syms)
+		return node.Type
+	} else if node.Tag == ast.Set {
+		node.Type = a.setCheck(node, syms)
+		return node.Type
+	} else if node.Tag == ast.Call {
+		// The result of an external procedure is an INTEGER.
+		if value := a.callCheck(node, syms); value != nil && !value.External {
+			a.appendError(node.Children[0].Tok)
+		}
+		node.Type = symtable.IntegerType
+		return node.Type
+	} else if node.Tag == ast.Index {
+		// Like a variable, an element that is a semaphore or a file can't be read.
+		typ := a.assignableCheck(node, syms)
+		if typ == symtable.SemaphoreType || typ == symtable.FileType {
+			a.appendError(node.Tok)
+			node.Type = nil
+		}
+		return node.Type
+	} else if node.Tag == ast.Conditional {
+		node.Type = a.conditionalCheck(node, syms)
+		return node.Type
+	} else if node.Tag == ast.Resume {
+		iden := node.Children[0]
+		if !a.findSymbolInTables(iden.Tok.Lex, symtable.Coroutine, syms) {
+			a.appendError(iden.Tok)
+		}
+		node.Type = symtable.IntegerType
+		return node.Type
 	}
 	left := node.Children[0]
-	a.recurseExpressionCheck(left, syms)
+	leftType := a.recurseExpressionCheck(left, syms)
 	right := node.Children[1]
-	a.recurseExpressionCheck(right, syms)
+	rightType := a.recurseExpressionCheck(right, syms)
+	// Division by a constant zero can be caught now rather than at runtime.
+	if node.Op == token.Divide && a.isConstantZero(right, syms) {
+		a.appendError(node.Tok)
+	}
+	node.Type = a.balanceCheck(node, leftType, rightType)
+	if !a.isArithmetic(node.Type, node.Op) {
+		a.appendError(node.Tok)
+		node.Type = nil
+	}
+	return node.Type
+}
+
+// conditionalCheck validates a conditional node in an expression and returns its type. The two arms
+// are balanced like the sides of a math node, so they must agree on a type, except that an INTEGER
+// arm is converted to a REAL to match a REAL arm. A conditional can't choose between whole arrays.
+func (a *Analyser) conditionalCheck(node *ast.Node, syms []*symtable.SymbolTable) *symtable.Type {
+	a.recurseConditionCheck(node.Children[2], syms)
+	then := a.recurseExpressionCheck(node.Children[0], syms)
+	els := a.recurseExpressionCheck(node.Children[1], syms)
+	typ := a.balanceCheck(node, then, els)
+	if isArray(typ) {
+		a.appendError(node.Tok)
+		return nil
+	}
+	return typ
+}
+
+// isArithmetic returns a bool representing whether or not an arithmetic operation can be done on a
+// type. INTEGERs and REALs have every operation. Sets have union (+), difference (-) and
+// intersection (*), and strings are joined with +. Enumerations and characters can be compared but
+// there's no arithmetic on them, and whole arrays can only be assigned. Types that are nil have
+// already caused an error, so they're allowed.
+func (a *Analyser) isArithmetic(typ *symtable.Type, op int) bool {
+	if typ == symtable.SetType {
+		return op != token.Divide
+	}
+	if typ == symtable.StringType {
+		return op == token.Plus
+	}
+	return typ == nil || typ == symtable.IntegerType || typ == symtable.RealType
+}
+
+// isArray returns a bool representing whether or not a type is an array type.
+func isArray(typ *symtable.Type) bool {
+	return typ != nil && typ.Kind == symtable.ArrayKind
+}
+
+// setCheck validates a set node in an expression and returns its type. Elements must be INTEGERs
+// and constant elements must be from 0 to 31.
+func (a *Analyser) setCheck(node *ast.Node, syms []*symtable.SymbolTable) *symtable.Type {
+	for _, elem := range node.Children {
+		bounds := []*ast.Node{elem}
+		if elem.Tag == ast.Range {
+			bounds = elem.Children
+		}
+		for _, expr := range bounds {
+			a.expectType(expr, a.recurseExpressionCheck(expr, syms), symtable.IntegerType)
+			if val, ok := a.constantValue(expr, syms); ok && (val < 0 || val > 31) {
+				a.appendError(expr.Tok)
+			}
+		}
+	}
+	return symtable.SetType
+}
+
+// terminalCheck validates a terminal node in an expression and returns its type.
+func (a *Analyser) terminalCheck(node *ast.Node, syms []*symtable.SymbolTable) *symtable.Type {
+	if node.Tok.Tag == token.Real {
+		return symtable.RealType
+	} else if node.Tok.Tag == token.String {
+		return symtable.StringType
+	} else if node.Tok.Tag != token.Identifier {
+		return symtable.IntegerType
+	}
+	// Only look through the symbol table if it's an idenfitier!
+	if value := a.lookupSymbolInTables(node.Tok.Lex, symtable.Integer, syms); value != nil {
+		// The count of a semaphore and the descriptor of a file can't be read.
+		if value.Type == symtable.SemaphoreType || value.Type == symtable.FileType {
+			a.appendError(node.Tok)
+			return nil
+		}
+		return value.Type
+	}
+	if value := a.lookupSymbolInTables(node.Tok.Lex, symtable.Constant, syms); value != nil {
+		return value.Type
+	}
+	a.appendError(node.Tok)
+	return nil
+}
+
+// functionCheck validates a function node in an expression and returns its type. FLOAT converts an
+// INTEGER to a REAL and TRUNC converts a REAL to an INTEGER. ORD converts an enumeration or a CHAR
+// (or an INTEGER) to an INTEGER. SUCC and PRED work on enumerations, CHARs and INTEGERs and keep
+// the type. LENGTH gives the number of characters in a STRING.
+func (a *Analyser) functionCheck(node *ast.Node, syms []*symtable.SymbolTable) *symtable.Type {
+	arg := node.Children[0]
+	typ := a.recurseExpressionCheck(arg, syms)
+	switch node.Op {
+	case token.Float:
+		a.expectType(arg, typ, symtable.IntegerType)
+		return symtable.RealType
+	case token.Trunc:
+		a.expectType(arg, typ, symtable.RealType)
+		return symtable.IntegerType
+	case token.Length:
+		a.expectType(arg, typ, symtable.StringType)
+		return symtable.IntegerType
+	case token.Ord:
+		if typ == symtable.RealType || typ == symtable.StringType || isArray(typ) {
+			a.appendError(arg.Tok)
+		}
+		return symtable.IntegerType
+	}
+	if typ == symtable.RealType || typ == symtable.StringType || isArray(typ) {
+		a.appendError(arg.Tok)
+		return nil
+	}
+	return typ
+}
+
+// balanceCheck validates that the two children of a math or condition node have compatible types
+// and returns the type the operation is done in. If one side is a REAL and the other is an INTEGER,
+// the INTEGER side is converted with FLOAT. If one side is a CHAR and the other is a string of one
+// character, the string is converted to the character.
+func (a *Analyser) balanceCheck(node *ast.Node, left *symtable.Type,
+	right *symtable.Type) *symtable.Type {
+	if left == nil || right == nil {
+		return nil
+	}
+	if symtable.Equivalent(left, right) {
+		return left
+	}
+	if left == symtable.RealType && right == symtable.IntegerType {
+		node.Children[1] = a.convertToReal(node.Children[1])
+		return left
+	} else if left == symtable.IntegerType && right == symtable.RealType {
+		node.Children[0] = a.convertToReal(node.Children[0])
+		return right
+	}
+	if char := a.convertToChar(node.Children[1]); left == symtable.CharType && char != nil {
+		node.Children[1] = char
+		return left
+	} else if char := a.convertToChar(node.Children[0]); right == symtable.CharType && char != nil {
+		node.Children[0] = char
+		return right
+	}
+	a.appendError(node.Children[0].Tok)
+	return nil
+}
+
+// isConstantZero returns a bool representing whether or not an expression node is an integer or a
+// constant that is 0.
+func (a *Analyser) isConstantZero(node *ast.Node, syms []*symtable.SymbolTable) bool {
+	val, ok := a.constantValue(node, syms)
+	return ok && val == 0
+}
+
+// constantValue returns the value of an expression node if it is an integer or a constant. INTEGER
+// arithmetic on those is folded as long as it doesn't divide by zero or overflow. The bool is false
+// if the value isn't known until runtime. The expression must have been checked already.
+func (a *Analyser) constantValue(node *ast.Node, syms []*symtable.SymbolTable) (int, bool) {
+	if node.Tag == ast.Math {
+		if node.Type != symtable.IntegerType {
+			return 0, false
+		}
+		left, ok := a.constantValue(node.Children[0], syms)
+		if !ok {
+			return 0, false
+		}
+		right, ok := a.constantValue(node.Children[1], syms)
+		if !ok {
+			return 0, false
+		}
+		val := 0
+		switch node.Op {
+		case token.Plus:
+			val = left + right
+		case token.Minus:
+			val = left - right
+		case token.Times:
+			val = left * right
+		case token.Divide:
+			if right == 0 {
+				return 0, false
+			}
+			val = left / right
+		}
+		if val < math.MinInt32 || val > math.MaxInt32 {
+			return 0, false
+		}
+		return val, true
+	}
+	if node.Tag != ast.Terminal {
+		return 0, false
+	}
+	if node.Tok.Tag == token.Integer {
+		return node.Tok.Val, true
+	} else if node.Tok.Tag != token.Identifier {
+		return 0, false
+	}
+	for i := len(syms) - 1; i >= 0; i-- {
+		if syms[i].Get(symtable.Key{symtable.Integer, node.Tok.Lex}) != nil {
+			// A variable shadows any constant of the same name.
+			return 0, false
+		}
+		if value := syms[i].Get(symtable.Key{symtable.Constant, node.Tok.Lex}); value != nil {
+			return value.Val, true
+		}
+	}
+	return 0, false
 }
 
-// recurseConditionCheck recurses on a condition.
+// recurseConditionCheck recurses on a condition. Both sides of a comparison are balanced like a
+// math node. Sets can only be compared for equality and inclusion (<= and >=) and arrays can't be
+// compared at all. IN tests whether an INTEGER is in a set. ODD only works on integers.
 func (a *Analyser) recurseConditionCheck(node *ast.Node, syms []*symtable.SymbolTable) {
-	if node.Tag == ast.Cond {
-		a.recurseExpressionCheck(node.Children[0], syms)
-		a.recurseExpressionCheck(node.Children[1], syms)
+	if node.Tag == ast.Cond && node.Op == token.In {
+		left := node.Children[0]
+		right := node.Children[1]
+		a.expectType(left, a.recurseExpressionCheck(left, syms), symtable.IntegerType)
+		a.expectType(right, a.recurseExpressionCheck(right, syms), symtable.SetType)
+	} else if node.Tag == ast.Cond {
+		left := a.recurseExpressionCheck(node.Children[0], syms)
+		right := a.recurseExpressionCheck(node.Children[1], syms)
+		typ := a.balanceCheck(node, left, right)
+		if typ == symtable.SetType && (node.Op == token.LessThan || node.Op == token.GreaterThan) ||
+			isArray(typ) {
+			a.appendError(node.Children[0].Tok)
+		}
 	} else if node.Tag == ast.Odd {
-		a.recurseExpressionCheck(node.Children[0], syms)
+		expr := node.Children[0]
+		a.expectType(expr, a.recurseExpressionCheck(expr, syms), symtable.IntegerType)
 	}
 }
 
 // findSymbolInTables returns a bool representing whether or not a symbol was found in the list of
 // symbol tables provided.
 func (a *Analyser) findSymbolInTables(lex string, symbol int, syms []*symtable.SymbolTable) bool {
-	// Go backwards so we search the closest table first. I don't think this matters, but it's
-	// better for clarity.
+	return a.lookupSymbolInTables(lex, symbol, syms) != nil
+}
+
+// lookupSymbolInTables returns the Value of a symbol from the closest of the symbol tables provided
+// which contains it, or nil if none of them do.
+func (a *Analyser) lookupSymbolInTables(lex string, symbol int,
+	syms []*symtable.SymbolTable) *symtable.Value {
+	// Go backwards so we search the closest table first.
 	for i := len(syms) - 1; i >= 0; i-- {
-		if syms[i].Get(symtable.Key{symbol, lex}) != nil {
-			return true
+		if value := syms[i].Get(symtable.Key{symbol, lex}); value != nil {
+			return value
 		}
 	}
-	return false
+	return nil
 }
 
 // appendError takes in a Token and appends a semantic error at the Token's line number to the
-// Analyser's error list.
+// Analyser's error list. Like a syntax error, it names the dialect the source was read in.
 func (a *Analyser) appendError(tok *token.Token) {
-	a.err = append(a.err, fmt.Errorf("Semantic error near line %d.", tok.Ln))
+	a.err = append(a.err, fmt.Errorf("Semantic error %s (%s dialect).", tok.Pos(), a.par.Dialect()))
 }
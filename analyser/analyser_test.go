@@ -1,6 +1,10 @@
 package analyser
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/saicheems/simplelang/lexer"
@@ -33,6 +37,300 @@ var tests = []testPair{
 		"\t\tc:=a+b;\n" +
 		"\tEND;\n" +
 		"CALL sum.\n", true},
+	{"VAR x;BEGIN x+=1;x-=2;x*=3;INC(x);DEC(x,x);END.", true},
+	{"CONST c=3;VAR x;BEGIN c+=1;END.", false},
+	{"CONST c=3;VAR x;BEGIN INC(c);END.", false},
+	{"VAR x;BEGIN INC(y);END.", false},
+	{"VAR x;BEGIN DEC(x, y);END.", false},
+	{"VAR x;PROCEDURE p;x+=1;BEGIN p*=2;END.", false},
+	{"VAR x;BEGIN WRITELN(x:3,\"x\":x);END.", true},
+	{"VAR x;BEGIN WRITELN(y);END.", false},
+	{"VAR x;BEGIN WRITELN(x:y);END.", false},
+	{"CONST c=2;VAR x;BEGIN ASSERT(x=c,c);END.", true},
+	{"VAR x;BEGIN ASSERT(y=3);END.", false},
+	{"VAR x;BEGIN ASSERT(x=3,y);END.", false},
+	{"VAR x;BEGIN x:=x/0;END.", false},
+	{"CONST z=0;VAR x;BEGIN x:=(x+1)/z;END.", false},
+	{"CONST z=0;VAR x,y;BEGIN x:=x/y;x:=x/(z+1);END.", true},
+	{"CONST z=2;VAR x;BEGIN x:=x/(z*3-6);END.", false},
+	{"CONST pi=3.14;VAR r:REAL,i;BEGIN r:=pi*i;r:=i;r:=2;i:=TRUNC(r);r+=1;! r;END.", true},
+	{"VAR r:REAL,i;BEGIN i:=r;END.", false},
+	{"VAR r:REAL,i;BEGIN i:=2.5;END.", false},
+	{"VAR r:REAL,i;BEGIN i:=i+r;END.", false},
+	{"VAR r:REAL,i;BEGIN i+=1.5;END.", false},
+	{"VAR r:REAL,i;BEGIN INC(r);END.", false},
+	{"VAR r:REAL,i;BEGIN INC(i,r);END.", false},
+	{"VAR r:REAL,i;BEGIN i:=TRUNC(i);END.", false},
+	{"VAR r:REAL,i;BEGIN r:=FLOAT(r);END.", false},
+	{"VAR r:REAL,i;BEGIN IF ODD r THEN i:=1;END.", false},
+	{"VAR r:REAL,i;BEGIN IF r<i THEN i:=1;IF 2.5>=r THEN i:=2;END.", true},
+	{"VAR r:REAL,i;BEGIN WRITELN(r, i:3);END.", true},
+	{"VAR r:REAL,i;BEGIN WRITELN(r:3);END.", false},
+	{"VAR r:REAL,i;BEGIN WRITELN(i:r);END.", false},
+	{"VAR r:REAL,i;BEGIN ASSERT(r>0,r);END.", false},
+	{"VAR r:REAL;PROCEDURE p;VAR i;i:=TRUNC(r);BEGIN r:=0.5;CALL p;END.", true},
+	{"VAR r:x;BEGIN r:=3;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN c:=G;c:=SUCC(c);i:=ORD(c);IF c<B THEN c:=PRED(B);END.", true},
+	{"TYPE C=(R,G,B);VAR c:C;PROCEDURE p;VAR d:C;d:=c;BEGIN c:=R;IF c=R THEN CALL p;END.", true},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN i:=c;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN c:=1;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN i:=R+1;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN c:=R+G;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN c+=G;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN INC(c);END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN IF c<1 THEN i:=1;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN ! c;END.", false},
+	{"TYPE C=(R,G,B);F=(X,Y);VAR c:C,f:F;BEGIN c:=X;END.", false},
+	{"TYPE C=(R,G,B);F=(X,Y);VAR c:C,f:F;BEGIN IF c=X THEN c:=R;END.", false},
+	{"TYPE C=(R,G,B);F=(X,R);VAR c:C;BEGIN c:=R;END.", false},
+	{"CONST R=1;TYPE C=(R,G,B);VAR c:C;BEGIN c:=R;END.", false},
+	{"TYPE C=(R,G,B);C=(X,Y);VAR c:C;BEGIN c:=R;END.", false},
+	{"TYPE C=(R,G,B);VAR R;BEGIN R:=1;END.", false},
+	{"TYPE C=(R,G,B);VAR r:REAL;BEGIN r:=SUCC(r);END.", false},
+	{"TYPE C=(R,G,B);VAR r:REAL,i;BEGIN i:=ORD(r);i:=ORD(i);END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i,n;BEGIN FOR c:=R TO B DO n+=ORD(c);FOR i:=n DOWNTO 1 DO ! i;END.", true},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN FOR c:=R TO 2 DO i:=1;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN FOR i:=R TO B DO i:=1;END.", false},
+	{"VAR r:REAL;BEGIN FOR r:=1 TO 2 DO ! r;END.", false},
+	{"CONST c=1;BEGIN FOR c:=1 TO 2 DO ! c;END.", false},
+	{"VAR i;BEGIN FOR i:=1 TO 2 DO ! j;END.", false},
+	{"CONST n=2;VAR i;BEGIN CASE i*2 OF 1,n:i:=0;-1,n+1:! i;n*3-1:i:=1;END;END.", true},
+	{"TYPE C=(R,G,B);VAR c:C;BEGIN CASE c OF R:c:=G;G,B:c:=R;END;END.", true},
+	{"TYPE C=(R,G,B);VAR c:C;BEGIN CASE c OF R:c:=G;1:c:=R;END;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C,i;BEGIN CASE i OF R:i:=1;END;END.", false},
+	{"TYPE C=(R,G,B);VAR c:C;BEGIN CASE c OF R:c:=G;G,R:c:=R;END;END.", false},
+	{"CONST n=2;VAR i;BEGIN CASE i OF 2:i:=0;n:i:=1;END;END.", false},
+	{"VAR i,j;BEGIN CASE i OF j:i:=0;END;END.", false},
+	{"VAR i;BEGIN CASE i OF 1/0:i:=0;END;END.", false},
+	{"VAR r:REAL;BEGIN CASE r OF 1:r:=0;END;END.", false},
+	{"VAR i;BEGIN CASE i OF 1.5:i:=0;END;END.", false},
+	{"VAR i;BEGIN CASE i OF 1:i:=j;END;END.", false},
+	{"CONST n=31;VAR s,t:SET OF 0..31,i;BEGIN s:={1,i,2..n};t:=s+{}-{0}*s;s+={4};s-=t;s*=t;END.",
+		true},
+	{"VAR s,t:SET OF 0..31,i;BEGIN IF i IN s+t THEN i:=1;IF s<=t THEN i:=2;IF s#{} THEN i:=3;END.",
+		true},
+	{"VAR s:SET OF 3..7;BEGIN s:={3};END.", true},
+	{"VAR s:SET OF 0..32;BEGIN s:={};END.", false},
+	{"VAR s:SET OF 7..3;BEGIN s:={};END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN s:={32};END.", false},
+	{"CONST n=-1;VAR s:SET OF 0..31,i;BEGIN s:={n..3};END.", false},
+	{"VAR s:SET OF 0..31,r:REAL;BEGIN s:={r};END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN s:=i;END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN i:=s;END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN s:=s/s;END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN s:=s+1;END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN s:=-s;END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN INC(s);END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN IF s<s THEN i:=1;END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN IF s IN s THEN i:=1;END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN IF i IN i THEN i:=1;END.", false},
+	{"VAR s:SET OF 0..31,i;BEGIN ! s;END.", false},
+	{"MODULE m;EXPORT x,c,p;CONST c=2;VAR x;PROCEDURE p;x:=c;.", true},
+	{"MODULE m;EXPORT y;VAR x;.", false},
+	{"MODULE m;EXPORT q;VAR x;PROCEDURE p;PROCEDURE q;x:=1;x:=2;.", false},
+	{"MODULE m;EXPORT c;TYPE C=(R,G);VAR c:C;.", false},
+	{"MODULE m;EXPORT C;TYPE C=(R,G);VAR c:C;.", false},
+	{"MODULE m;EXPORT s,r;VAR s:SET OF 0..31,r:REAL;.", true},
+	{"IMPORT nosuchmodule;VAR x;x:=1.", false},
+	{"VAR x;PROCEDURE p;STATIC r:REAL,n;BEGIN n+=1;r:=n;x:=n;END;CALL p.", true},
+	{"PROCEDURE p;STATIC n;PROCEDURE q;n:=2;CALL q;CALL p.", true},
+	{"VAR x;PROCEDURE p;STATIC n;n:=1;x:=n.", false},
+	{"PROCEDURE p;VAR n;STATIC n;n:=1;CALL p.", false},
+	{"CONST n=1;PROCEDURE p;STATIC n;n:=1;CALL p.", false},
+	{"PROCEDURE p;STATIC n:x;n:=1;CALL p.", false},
+	{"MODULE m;EXPORT n;STATIC n;.", true},
+	{"CONST e=2;VAR x;TRY RAISE x;EXCEPT 1:x:=1;e:RAISE e+1;END.", true},
+	{"VAR x;TRY x:=1;EXCEPT x:x:=2;END.", false},
+	{"CONST e=1;VAR x;TRY x:=1;EXCEPT 1:x:=2;e:x:=3;END.", false},
+	{"VAR x;TRY x:=1;EXCEPT 1+1:x:=2;END.", true},
+	{"VAR x;TRY x:=1;EXCEPT 1/0:x:=2;END.", false},
+	{"TYPE c=(red);VAR x;TRY x:=1;EXCEPT red:x:=2;END.", false},
+	{"VAR x;TRY y:=1;EXCEPT 1:x:=2;END.", false},
+	{"VAR x;TRY x:=1;EXCEPT 1:y:=2;END.", false},
+	{"VAR r:REAL;RAISE r.", false},
+	{"VAR x;COROUTINE g;VAR i;BEGIN i:=1;WHILE 1=1 DO BEGIN YIELD i;i:=i*2;END;END;x:=RESUME g+1.", true},
+	{"VAR x;COROUTINE g;PROCEDURE p;YIELD x;CALL p;x:=RESUME g.", true},
+	{"COROUTINE f;YIELD 1;COROUTINE g;YIELD RESUME f;! RESUME g.", true},
+	{"VAR x;PROCEDURE p;YIELD x;CALL p.", false},
+	{"YIELD 1.", false},
+	{"PROCEDURE p;COROUTINE g;YIELD 1;! RESUME g;CALL p.", false},
+	{"COROUTINE g;YIELD 1;CALL g.", false},
+	{"PROCEDURE p;! 1;! RESUME p.", false},
+	{"VAR x;! RESUME x.", false},
+	{"PROCEDURE g;! 1;COROUTINE g;YIELD 1;CALL g.", false},
+	{"VAR r:REAL;COROUTINE g;YIELD r;! RESUME g.", false},
+	{"VAR r:REAL;COROUTINE g;YIELD 1;r:=RESUME g.", true},
+	{"MODULE m;EXPORT g;COROUTINE g;YIELD 1;.", false},
+	{"VAR s:SEMAPHORE;PROCEDURE p;WAIT(s);PROCEDURE q;SIGNAL(s);BEGIN s:=0;COBEGIN CALL p;CALL q COEND;END.", true},
+	{"PROCEDURE p;! 1;BEGIN IF 1=1 THEN COBEGIN CALL p;CALL p COEND;END.", true},
+	{"VAR s:SEMAPHORE;PROCEDURE p;VAR t:SEMAPHORE;BEGIN t:=1;WAIT(t);SIGNAL(s);END;CALL p.", true},
+	{"PROCEDURE p;! 1;PROCEDURE q;COBEGIN CALL p COEND;CALL q.", false},
+	{"COROUTINE g;COBEGIN CALL g COEND;! RESUME g.", false},
+	{"PROCEDURE p;! 1;COBEGIN CALL p;CALL r COEND.", false},
+	{"VAR x;WAIT(x).", false},
+	{"VAR s:SEMAPHORE;SIGNAL(t).", false},
+	{"VAR s:SEMAPHORE,x;x:=s.", false},
+	{"VAR s:SEMAPHORE;! s.", false},
+	{"VAR s:SEMAPHORE;s+=1.", false},
+	{"VAR s:SEMAPHORE;INC(s).", false},
+	{"VAR s:SEMAPHORE;IF s=1 THEN ! 1.", false},
+	{"VAR r:REAL,s:SEMAPHORE;s:=r.", false},
+	{"CONST c=1;WAIT(c).", false},
+	{"MODULE m;EXPORT s;VAR s:SEMAPHORE;.", false},
+	{"VAR x;PROCEDURE p;PROCEDURE q;HALT(x+1);CALL q;BEGIN CALL p;HALT;END.", true},
+	{"CONST c=3;HALT(c).", true},
+	{"MODULE m;PROCEDURE p;HALT(2);.", true},
+	{"VAR r:REAL;HALT(r).", false},
+	{"HALT(y).", false},
+	{"VAR f:FILE,x;BEGIN OPEN(f,\"a\");READ(f,x);CLOSE(f);OPEN(f,\"b\",x+1);WRITELN(f,x:3,\"c\");END.", true},
+	{"VAR f:FILE;PROCEDURE p;VAR x;BEGIN READ(f,x);WRITE(f,x);END;CALL p.", true},
+	{"VAR f:FILE;PROCEDURE p;VAR g:FILE;OPEN(g,\"a\");CALL p.", true},
+	{"VAR f:FILE;WRITE(f).", true},
+	{"VAR x;OPEN(x,\"a\").", false},
+	{"VAR f:FILE;OPEN(g,\"a\").", false},
+	{"VAR f:FILE,r:REAL;OPEN(f,\"a\",r).", false},
+	{"VAR f:FILE,r:REAL;READ(f,r).", false},
+	{"CONST c=1;VAR f:FILE;READ(f,c).", false},
+	{"VAR f:FILE;READ(f,f).", false},
+	{"VAR f:FILE;WRITE(f,1.5).", false},
+	{"VAR f:FILE;WRITE(f:3,1).", false},
+	{"VAR f:FILE;! f.", false},
+	{"VAR f,g:FILE;f:=g.", false},
+	{"VAR f:FILE;f:=1.", false},
+	{"VAR f:FILE;f+=1.", false},
+	{"MODULE m;EXPORT f;VAR f:FILE;.", false},
+	{"VAR x;PROCEDURE p;VAR a;PROCEDURE q;STATIC s;ASM \"lw $t0, {a}\" \"sw $t0, {s}\" \"sw $t0, {x}\" END;CALL q;CALL p.", true},
+	{"VAR r:REAL,s:SEMAPHORE;ASM \"lw $t0, {r}\" \"lw $t0, {s}\" END.", true},
+	{"ASM \"lw $t0, {x}\" END.", false},
+	{"CONST c=1;ASM \"lw $t0, {c}\" END.", false},
+	{"PROCEDURE p;VAR a;! 1;ASM \"lw $t0, {a}\" END.", false},
+	{"PROCEDURE p;! 1;ASM \"lw $t0, {p}\" END.", false},
+	{"VAR x;EXTERNAL PROCEDURE max(a,b);EXTERNAL PROCEDURE tick;BEGIN x:=max(x,1)+max(2,max(3,4));CALL tick;CALL max(1,2);END.", true},
+	{"EXTERNAL PROCEDURE f(a,b,c,d);PROCEDURE p;EXTERNAL PROCEDURE g;CALL g;BEGIN CALL p;! f(1,2,3,4);END.", true},
+	{"MODULE m;EXPORT p;EXTERNAL PROCEDURE f(a);PROCEDURE p;CALL f(1);.", true},
+	{"EXTERNAL PROCEDURE f(a,b,c,d,e);CALL f(1,2,3,4,5).", false},
+	{"EXTERNAL PROCEDURE f(a);CALL f.", false},
+	{"EXTERNAL PROCEDURE f(a);CALL f(1,2).", false},
+	{"VAR r:REAL;EXTERNAL PROCEDURE f(a);CALL f(r).", false},
+	{"VAR r:REAL;EXTERNAL PROCEDURE f(a);r:=f(1).", true},
+	{"PROCEDURE p;! 1;CALL p(1).", false},
+	{"VAR x;PROCEDURE p;! 1;x:=p().", false},
+	{"VAR x;x:=g(1).", false},
+	{"EXTERNAL PROCEDURE f;PROCEDURE f;! 1;CALL f.", false},
+	{"PROCEDURE p;! 1;PROCEDURE p;! 2;CALL p.", false},
+	{"EXTERNAL PROCEDURE f;COROUTINE f;YIELD 1;CALL f.", false},
+	{"EXTERNAL PROCEDURE f;PROCEDURE p;! 1;COBEGIN CALL p;CALL f COEND.", false},
+	{"MODULE m;EXPORT f;EXTERNAL PROCEDURE f;.", false},
+	{"TYPE Index=INTEGER;Vec=ARRAY 10 OF Index;VAR v:Vec,i:Index;BEGIN i:=3;v[i]:=i+1;! v[i]; END.", true},
+	{"TYPE Grid=ARRAY 2 OF Row;Row=ARRAY 3 OF INTEGER;VAR g:Grid;g[1][2]:=1.", true},
+	{"TYPE A=ARRAY 3 OF INTEGER;B=ARRAY 3 OF INTEGER;VAR a:A,b:B,c:ARRAY 3 OF INTEGER;BEGIN a:=b;c:=a; END.", true},
+	{"TYPE A=ARRAY 3 OF INTEGER;VAR a:A,b:ARRAY 4 OF INTEGER;a:=b.", false},
+	{"TYPE A=ARRAY 3 OF INTEGER;VAR a:A,b:ARRAY 3 OF REAL;a:=b.", false},
+	{"TYPE C=(Red,Green);D=(Blue,Pink);E=C;VAR x:E;x:=Red.", true},
+	{"TYPE C=(Red,Green);D=(Blue,Pink);VAR x:D;x:=Red.", false},
+	{"TYPE T=T;VAR x:T;x:=1.", false},
+	{"TYPE T=ARRAY 3 OF T;VAR x;x:=1.", false},
+	{"TYPE A=B;B=ARRAY 2 OF A;VAR x;x:=1.", false},
+	{"TYPE A=Missing;VAR x;x:=1.", false},
+	{"VAR x:Missing;x:=1.", false},
+	{"TYPE T=INTEGER;PROCEDURE p;TYPE U=ARRAY 2 OF T;VAR u:U;u[1]:=1;CALL p.", true},
+	{"PROCEDURE p;TYPE U=INTEGER;! 1;VAR x:U;x:=1.", false},
+	{"TYPE T=INTEGER;T=REAL;VAR x;x:=1.", false},
+	{"VAR v:ARRAY 0 OF INTEGER;v[0]:=1.", false},
+	{"VAR v:ARRAY 3 OF INTEGER;v[3]:=1.", false},
+	{"CONST n=-1;VAR v:ARRAY 3 OF INTEGER;v[n]:=1.", false},
+	{"VAR v:ARRAY 3 OF INTEGER;v[1.5]:=1.", false},
+	{"VAR v:ARRAY 3 OF INTEGER,x;x[1]:=1.", false},
+	{"VAR v:ARRAY 3 OF INTEGER;v:=1.", false},
+	{"VAR v,w:ARRAY 3 OF INTEGER;v:=v+w.", false},
+	{"VAR v,w:ARRAY 3 OF INTEGER;IF v=w THEN ! 1.", false},
+	{"VAR v:ARRAY 3 OF INTEGER;! v.", false},
+	{"VAR v:ARRAY 3 OF INTEGER,x;x:=ORD(v).", false},
+	{"VAR v:ARRAY 3 OF INTEGER;INC(v).", false},
+	{"VAR v:ARRAY 3 OF REAL;BEGIN v[0]:=1;v[1]+=2.5;! v[0]+v[1]; END.", true},
+	{"VAR s:ARRAY 2 OF SEMAPHORE,x;x:=s[0].", false},
+	{"MODULE m;EXPORT v;VAR v:ARRAY 2 OF INTEGER;.", false},
+	{"VAR s,t:STRING,c:CHAR,n;BEGIN s:=\"ab\"+t;t+=s;c:=s[1];n:=LENGTH(s)+ORD(c);WRITELN(s:5,c,t);END.", true},
+	{"VAR s,t:STRING;IF s<t THEN ! 1.", true},
+	{"VAR s:STRING,c:CHAR;BEGIN c:=\"x\";IF c=\"y\" THEN ! 1;IF \"y\"<c THEN ! 2;END.", true},
+	{"TYPE Name=STRING;VAR a:ARRAY 2 OF Name,c:CHAR;c:=a[1][0].", true},
+	{"VAR c:CHAR;c:=\"xy\".", false},
+	{"VAR c:CHAR;c:=1.", false},
+	{"VAR c:CHAR,s:STRING;s:=c.", false},
+	{"VAR s:STRING;s:=1.", false},
+	{"VAR x;x:=\"a\".", false},
+	{"VAR s:STRING;s:=s-\"a\".", false},
+	{"VAR s:STRING;s:=s*\"a\".", false},
+	{"VAR s:STRING;s:=s+1.", false},
+	{"VAR s:STRING;s[0]:=\"a\".", false},
+	{"VAR s:STRING,x;x:=s[\"a\"].", false},
+	{"VAR s:STRING,x;x:=LENGTH(x).", false},
+	{"VAR s:STRING,x;x:=ORD(s).", false},
+	{"VAR s:STRING;s:=SUCC(s).", false},
+	{"VAR c:CHAR;c:=c+c.", false},
+	{"VAR s:STRING;IF s=1 THEN ! 1.", false},
+	{"VAR s:STRING;! s.", false},
+	{"VAR c:CHAR;WRITE(c:3).", false},
+	{"VAR f:FILE,c:CHAR;WRITE(f,c).", false},
+	{"VAR f:FILE,s:STRING;WRITE(f,s:3).", true},
+	{"VAR x;WRITE(x:\"a\").", false},
+	{"VAR x,y;BEGIN x:=IF y<3 THEN y ELSE -y;WRITELN(IF ODD x THEN 1 ELSE 2*x);END.", true},
+	{"VAR r:REAL,y;BEGIN r:=IF y<3 THEN 1 ELSE 0.5;r:=IF y<3 THEN r ELSE y;END.", true},
+	{"VAR x,y;x:=IF y<3 THEN 1 ELSE 0.5.", false},
+	{"VAR s:STRING,c:CHAR,y;BEGIN s:=IF y<3 THEN \"a\" ELSE s+\"b\";c:=IF y<3 THEN s[0] ELSE \"z\";END.", true},
+	{"VAR s:STRING,y;y:=IF y<3 THEN s ELSE 1.", false},
+	{"TYPE C=(Red,Green);VAR c:C,y;c:=IF y<3 THEN Red ELSE Green.", true},
+	{"TYPE C=(Red,Green);VAR c:C,y;c:=IF y<3 THEN Red ELSE 1.", false},
+	{"VAR a,b:ARRAY 2 OF INTEGER,y;a:=IF y<3 THEN a ELSE b.", false},
+	{"VAR x,y;x:=IF z<3 THEN 1 ELSE 2.", false},
+	{"VAR x,y;x:=IF y<3 THEN z ELSE 2.", false},
+	{"VAR x,y;x:=IF y<3 THEN 1 ELSE 2/0.", false},
+	{"VAR x,y;BEGIN x:=IF y<3 THEN 1 ELSE 2;IF x=(IF y<3 THEN 1 ELSE 2) THEN ! x;END.", true},
+}
+
+// importInterface is the interface file of the module imported by importTests.
+var importInterface = "CONST limit INTEGER 100\n" +
+	"VAR x INTEGER calc_x\n" +
+	"VAR r REAL calc_r\n" +
+	"PROCEDURE gcd calc_procedure0 2\n"
+
+var importTests = []testPair{
+	{"IMPORT calc;VAR i;BEGIN x:=limit;CALL gcd;r:=x;i:=x;END.", true},
+	{"IMPORT calc;PROCEDURE p;VAR x;x:=1;CALL p.", true},
+	{"IMPORT calc;VAR x;x:=1.", false},
+	{"IMPORT calc;CONST limit=3;VAR i;i:=limit.", false},
+	{"IMPORT calc;PROCEDURE gcd;x:=1;CALL gcd.", false},
+	{"IMPORT calc,calc;VAR i;i:=limit.", false},
+	{"IMPORT calc;VAR i;BEGIN i:=r;END.", false},
+	{"IMPORT calc;VAR i;BEGIN limit:=1;END.", false},
+	{"MODULE m;IMPORT calc;EXPORT x,gcd;.", true},
+}
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "analyser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "calc.int"), []byte(importInterface), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range importTests {
+		l := lexer.NewFromString(pair.test)
+		p := parser.New(l)
+		a := New(p)
+		a.SetModulePath([]string{dir})
+		pass := a.Analyse() != nil
+
+		if pass != pair.expect {
+			t.Error(
+				"\nFor\n------\n"+pair.test,
+				"\n------\nExpected\n------\n", pair.expect,
+				"\n------\nGot\n------\n", pass,
+			)
+		}
+	}
 }
 
 func TestAnalyse(t *testing.T) {
@@ -51,3 +349,15 @@ func TestAnalyse(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorDialect(t *testing.T) {
+	l := lexer.NewFromString("var x;\nbegin y := 1; end.")
+	l.SetDialect(lexer.Wirth)
+	a := New(parser.New(l))
+	if a.Analyse() != nil || len(a.err) == 0 {
+		t.Fatal("Expected a semantic error.")
+	}
+	if got := a.err[0].Error(); !strings.HasSuffix(got, "(wirth dialect).") {
+		t.Error("Expected the error to name the wirth dialect, got " + got)
+	}
+}
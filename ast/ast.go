@@ -7,22 +7,55 @@ import (
 )
 
 const (
-	Program         = iota // The parent node.
-	Block                  // Contains a set of statements.
-	Const                  // ex. CONST a = 3, b = 4;
-	Var                    // ex. VAR a, b;
-	ProcedureParent        // Contains a set of procedure nodes.
-	Procedure              // ex. PROCEDURE a; BLOCK
-	Call                   // ex. CALL a;
-	Begin                  // ex. BEGIN stmt END;
-	IfThen                 // ex. IF cond THEN stmt;
-	WhileDo                // ex. WHILE cond DO stmt;
-	Odd                    // ex. ODD expr;
-	Cond                   // ex. a == b; x # y;
-	Math                   // Forms mathematical expressions.
-	Assignment             // ex. a := 3;
-	Terminal               // Contains a identifier token or an integer token.
-	Print                  // ex. !X prints X.
+	Program            = iota // The parent node.
+	Block                     // Contains a set of statements.
+	Const                     // ex. CONST a = 3, b = 4;
+	Var                       // ex. VAR a, b;
+	ProcedureParent           // Contains a set of procedure nodes.
+	Procedure                 // ex. PROCEDURE a; BLOCK
+	Call                      // ex. CALL a;
+	Begin                     // ex. BEGIN stmt END;
+	IfThen                    // ex. IF cond THEN stmt;
+	WhileDo                   // ex. WHILE cond DO stmt;
+	Odd                       // ex. ODD expr;
+	Cond                      // ex. a == b; x # y;
+	Math                      // Forms mathematical expressions.
+	Assignment                // ex. a := 3;
+	Terminal                  // Contains a identifier token or an integer token.
+	Print                     // ex. !X prints X.
+	CompoundAssignment        // ex. a += 3; INC(a);
+	Write                     // ex. WRITE(a, "b"); WRITELN(a:5);
+	Format                    // ex. a:5 in a WRITE statement.
+	Assert                    // ex. ASSERT(cond); ASSERT(cond, code);
+	Type                      // ex. INTEGER, REAL in VAR a: REAL;
+	Function                  // ex. FLOAT(a), TRUNC(b), LENGTH(s)
+	TypeDecl                  // ex. TYPE Colour = (Red, Green); Vec = ARRAY 3 OF INTEGER;
+	Enum                      // ex. (Red, Green) in TYPE Colour = (Red, Green);
+	For                       // ex. FOR i := 1 TO 10 DO stmt;
+	Case                      // ex. CASE c OF Red: stmt; Green, Blue: stmt END;
+	Arm                       // ex. Green, Blue: stmt in a CASE statement.
+	Set                       // ex. {1, 3, 5..7}
+	Range                     // ex. 5..7 in {1, 3, 5..7}
+	Import                    // ex. IMPORT calc, util;
+	Export                    // ex. EXPORT gcd, x;
+	Static                    // ex. STATIC a, b;
+	Try                       // ex. TRY stmt; EXCEPT 1: stmt; END
+	Handler                   // ex. 1: stmt; in TRY stmt; EXCEPT 1: stmt; END
+	Raise                     // ex. RAISE 3;
+	Coroutine                 // ex. COROUTINE a; BLOCK
+	Yield                     // ex. YIELD x;
+	Resume                    // ex. RESUME a
+	Cobegin                   // ex. COBEGIN CALL p; CALL q COEND
+	Wait                      // ex. WAIT(s);
+	Signal                    // ex. SIGNAL(s);
+	Halt                      // ex. HALT(1);
+	Open                      // ex. OPEN(f, "data.txt");
+	Close                     // ex. CLOSE(f);
+	Read                      // ex. READ(f, a, b);
+	Asm                       // ex. ASM "lw $t0, {x}" END;
+	External                  // ex. EXTERNAL PROCEDURE max(a, b);
+	Index                     // ex. v[i] in x := v[i] + 1;
+	Conditional               // ex. IF a < b THEN a ELSE b
 )
 
 // Represents a single node of the abstract syntax tree.
@@ -31,6 +64,7 @@ type Node struct {
 	Op       int                   // An operation: +, -, *, /, =, #, ...
 	Tok      *token.Token          // Token for terminal nodes.
 	Sym      *symtable.SymbolTable // Symbol table that encloses scope of this Node's children.
+	Type     *symtable.Type        // Type of an expression. Set by the analyser.
 	Children []*Node               // Contains all children of this node.
 }
 
@@ -48,17 +82,34 @@ func (n *Node) AppendNode(node ...*Node) {
 	n.Children = append(n.Children, node...)
 }
 
-// NewProgramNode returns a new program Node given a block Node.
-func NewProgramNode(block *Node) *Node {
+// NewProgramNode returns a new program Node given the Token naming the module, a block Node, an
+// import Node and an export Node. The Token is nil if the program isn't a module.
+func NewProgramNode(name *token.Token, block *Node, imports *Node, exports *Node) *Node {
 	node := NewNode(Program)
-	node.AppendNode(block)
+	node.Tok = name
+	node.AppendNode(block, imports, exports)
 	return node
 }
 
-// NewBlockNode returns a new block Node given const, var, procedure and statement Nodes.
-func NewBlockNode(cons *Node, vars *Node, proc *Node, stmt *Node) *Node {
+// NewImportNode returns a new import Node. The import Node should enclose a set of terminal Nodes
+// naming modules.
+func NewImportNode() *Node {
+	node := NewNode(Import)
+	return node
+}
+
+// NewExportNode returns a new export Node. The export Node should enclose a set of terminal Nodes
+// naming the exported constants, variables and procedures.
+func NewExportNode() *Node {
+	node := NewNode(Export)
+	return node
+}
+
+// NewBlockNode returns a new block Node given const, type, var, static, procedure and statement
+// Nodes.
+func NewBlockNode(cons *Node, typs *Node, vars *Node, stat *Node, proc *Node, stmt *Node) *Node {
 	node := NewNode(Block)
-	node.AppendNode(cons, vars, proc, stmt)
+	node.AppendNode(cons, typs, vars, stat, proc, stmt)
 	return node
 }
 
@@ -68,12 +119,27 @@ func NewConstNode() *Node {
 	return node
 }
 
+// NewTypeDeclNode returns a new type declaration Node. It should enclose a set of assignment Nodes
+// from a terminal Node naming the type to the Node describing it, which is either an enumeration
+// Node or a type Node.
+func NewTypeDeclNode() *Node {
+	node := NewNode(TypeDecl)
+	return node
+}
+
 // NewVarNode returns a new var Node.
 func NewVarNode() *Node {
 	node := NewNode(Var)
 	return node
 }
 
+// NewStaticNode returns a new static Node. It encloses identifiers like a var Node, but the
+// variables keep their values between calls.
+func NewStaticNode() *Node {
+	node := NewNode(Static)
+	return node
+}
+
 // NewProcedureParentNode returns a new procedure parent Node. The procedure parent Node should
 // enclose a set of procedure Nodes.
 func NewProcedureParentNode() *Node {
@@ -88,7 +154,25 @@ func NewProcedureNode(iden *Node, bloc *Node) *Node {
 	return node
 }
 
-// NewCallNode returns a new call Node given a terminal Node.
+// NewCoroutineNode returns a new coroutine Node given a terminal Node and a block Node. Coroutines
+// are kept with the procedures under the procedure parent Node.
+func NewCoroutineNode(iden *Node, bloc *Node) *Node {
+	node := NewNode(Coroutine)
+	node.AppendNode(iden, bloc)
+	return node
+}
+
+// NewExternalNode returns a new external Node given a terminal Node. The external Node should
+// enclose a terminal Node for each argument. External procedures are kept with the procedures under
+// the procedure parent Node, but they have no block.
+func NewExternalNode(iden *Node) *Node {
+	node := NewNode(External)
+	node.AppendNode(iden)
+	return node
+}
+
+// NewCallNode returns a new call Node given a terminal Node. A call of an external procedure should
+// enclose an expression Node for each argument after it.
 func NewCallNode(iden *Node) *Node {
 	node := NewNode(Call)
 	node.AppendNode(iden)
@@ -115,6 +199,33 @@ func NewWhileDoNode(cond *Node, stmt *Node) *Node {
 	return node
 }
 
+// NewForNode returns a new for Node given the FOR Token, the TO or DOWNTO Token tag, an assignment
+// Node initializing the control variable, a limit expression Node and a statement Node.
+func NewForNode(tok *token.Token, op int, init *Node, limit *Node, stmt *Node) *Node {
+	node := NewNode(For)
+	node.Op = op
+	node.Tok = tok
+	node.AppendNode(init, limit, stmt)
+	return node
+}
+
+// NewCaseNode returns a new case Node given the CASE Token and a selector expression Node. The case
+// Node should enclose a set of arm Nodes after the selector.
+func NewCaseNode(tok *token.Token, expr *Node) *Node {
+	node := NewNode(Case)
+	node.Tok = tok
+	node.AppendNode(expr)
+	return node
+}
+
+// NewArmNode returns a new arm Node given the statement of the arm. The arm Node should enclose the
+// label expression Nodes of the arm after the statement.
+func NewArmNode(stmt *Node) *Node {
+	node := NewNode(Arm)
+	node.AppendNode(stmt)
+	return node
+}
+
 // NewOddNode returns a new odd Node given an expression Node.
 func NewOddNode(expr *Node) *Node {
 	node := NewNode(Odd)
@@ -122,11 +233,13 @@ func NewOddNode(expr *Node) *Node {
 	return node
 }
 
-// NewMathNode returns a new math Node given an operation, a left hand expression Node and a right
-// hand expression Node.
-func NewMathNode(op int, left *Node, right *Node) *Node {
+// NewMathNode returns a new math Node given an operation, the operator Token, a left hand
+// expression Node and a right hand expression Node. The Token locates the operation in the source
+// for runtime errors.
+func NewMathNode(op int, tok *token.Token, left *Node, right *Node) *Node {
 	node := NewNode(Math)
 	node.Op = op
+	node.Tok = tok
 	node.AppendNode(left, right)
 	return node
 }
@@ -148,6 +261,18 @@ func NewAssignmentNode(left *Node, right *Node) *Node {
 	return node
 }
 
+// NewCompoundAssignmentNode returns a new compound assignment Node given an operation, the operator
+// Token, a left hand terminal Node and a right hand expression Node. The operation is applied to
+// the current value of the left hand side and the right hand side, and the result is stored back in
+// the left hand side.
+func NewCompoundAssignmentNode(op int, tok *token.Token, left *Node, right *Node) *Node {
+	node := NewNode(CompoundAssignment)
+	node.Op = op
+	node.Tok = tok
+	node.AppendNode(left, right)
+	return node
+}
+
 // NewPrintNode returns a new print Node given an expression to print.
 func NewPrintNode(expr *Node) *Node {
 	node := NewNode(Print)
@@ -155,7 +280,222 @@ func NewPrintNode(expr *Node) *Node {
 	return node
 }
 
-// NewTerminalNode returns a new terminal Node given a terminal Token (Identifier or Integer).
+// NewWriteNode returns a new write Node given the WRITE or WRITELN token tag. The write Node should
+// enclose a set of format Nodes.
+func NewWriteNode(op int) *Node {
+	node := NewNode(Write)
+	node.Op = op
+	return node
+}
+
+// NewFormatNode returns a new format Node given an expression or string terminal Node and a width
+// expression Node. The width may be nil if no width was given.
+func NewFormatNode(item *Node, width *Node) *Node {
+	node := NewNode(Format)
+	node.AppendNode(item)
+	if width != nil {
+		node.AppendNode(width)
+	}
+	return node
+}
+
+// NewAssertNode returns a new assert Node given the ASSERT token, a condition Node and an exit code
+// expression Node. The exit code may be nil if no code was given.
+func NewAssertNode(tok *token.Token, cond *Node, code *Node) *Node {
+	node := NewNode(Assert)
+	node.Tok = tok
+	node.AppendNode(cond)
+	if code != nil {
+		node.AppendNode(code)
+	}
+	return node
+}
+
+// NewTryNode returns a new try Node given the TRY token and a begin Node holding the statements
+// that are tried. The try Node should also enclose a set of handler Nodes after the begin Node.
+func NewTryNode(tok *token.Token, body *Node) *Node {
+	node := NewNode(Try)
+	node.Tok = tok
+	node.AppendNode(body)
+	return node
+}
+
+// NewHandlerNode returns a new handler Node given an exception code expression Node and the
+// statement Node that handles the exception.
+func NewHandlerNode(code *Node, stmt *Node) *Node {
+	node := NewNode(Handler)
+	node.AppendNode(code, stmt)
+	return node
+}
+
+// NewRaiseNode returns a new raise Node given the RAISE token and an exception code expression
+// Node.
+func NewRaiseNode(tok *token.Token, code *Node) *Node {
+	node := NewNode(Raise)
+	node.Tok = tok
+	node.AppendNode(code)
+	return node
+}
+
+// NewYieldNode returns a new yield Node given the YIELD token and the expression Node yielded.
+func NewYieldNode(tok *token.Token, expr *Node) *Node {
+	node := NewNode(Yield)
+	node.Tok = tok
+	node.AppendNode(expr)
+	return node
+}
+
+// NewResumeNode returns a new resume Node given the RESUME token and a terminal Node naming the
+// coroutine.
+func NewResumeNode(tok *token.Token, iden *Node) *Node {
+	node := NewNode(Resume)
+	node.Tok = tok
+	node.AppendNode(iden)
+	return node
+}
+
+// NewCobeginNode returns a new cobegin Node given the COBEGIN token. The cobegin Node should
+// enclose a set of call Nodes, one for each process.
+func NewCobeginNode(tok *token.Token) *Node {
+	node := NewNode(Cobegin)
+	node.Tok = tok
+	return node
+}
+
+// NewWaitNode returns a new wait Node given the WAIT token and a terminal Node naming the
+// semaphore.
+func NewWaitNode(tok *token.Token, iden *Node) *Node {
+	node := NewNode(Wait)
+	node.Tok = tok
+	node.AppendNode(iden)
+	return node
+}
+
+// NewSignalNode returns a new signal Node given the SIGNAL token and a terminal Node naming the
+// semaphore.
+func NewSignalNode(tok *token.Token, iden *Node) *Node {
+	node := NewNode(Signal)
+	node.Tok = tok
+	node.AppendNode(iden)
+	return node
+}
+
+// NewHaltNode returns a new halt Node given the HALT token and an exit status expression Node. The
+// status is nil for a HALT without one.
+func NewHaltNode(tok *token.Token, code *Node) *Node {
+	node := NewNode(Halt)
+	node.Tok = tok
+	if code != nil {
+		node.AppendNode(code)
+	}
+	return node
+}
+
+// NewOpenNode returns a new open Node given the OPEN token, a terminal Node naming the file, a
+// string terminal Node with the file name and a mode expression Node. The mode may be nil if none
+// was given.
+func NewOpenNode(tok *token.Token, iden *Node, name *Node, mode *Node) *Node {
+	node := NewNode(Open)
+	node.Tok = tok
+	node.AppendNode(iden, name)
+	if mode != nil {
+		node.AppendNode(mode)
+	}
+	return node
+}
+
+// NewCloseNode returns a new close Node given the CLOSE token and a terminal Node naming the file.
+func NewCloseNode(tok *token.Token, iden *Node) *Node {
+	node := NewNode(Close)
+	node.Tok = tok
+	node.AppendNode(iden)
+	return node
+}
+
+// NewReadNode returns a new read Node given the READ token and a terminal Node naming the file. The
+// read Node should enclose a terminal Node for each variable that is read.
+func NewReadNode(tok *token.Token, iden *Node) *Node {
+	node := NewNode(Read)
+	node.Tok = tok
+	node.AppendNode(iden)
+	return node
+}
+
+// NewAsmNode returns a new asm Node given the ASM token. The asm Node should enclose a string
+// terminal Node for each line of assembly, which in turn encloses the terminal Node of the variable
+// the line refers to, if any.
+func NewAsmNode(tok *token.Token) *Node {
+	node := NewNode(Asm)
+	node.Tok = tok
+	return node
+}
+
+// NewTypeNode returns a new type Node given the Token naming the type. The Token is either a type
+// keyword or the identifier of a declared type. A SET type Node gets its bounds as children and an
+// ARRAY type Node gets its length and the type Node of its elements.
+func NewTypeNode(tok *token.Token) *Node {
+	node := NewNode(Type)
+	node.Tok = tok
+	return node
+}
+
+// NewEnumNode returns a new enumeration Node. It should enclose the terminal Nodes naming the
+// values of the enumeration in order.
+func NewEnumNode() *Node {
+	node := NewNode(Enum)
+	return node
+}
+
+// NewSetNode returns a new set Node given the left curly brace Token. The set Node should enclose a
+// set of expression and range Nodes for its elements.
+func NewSetNode(tok *token.Token) *Node {
+	node := NewNode(Set)
+	node.Tok = tok
+	return node
+}
+
+// NewRangeNode returns a new range Node given the range Token, a low expression Node and a high
+// expression Node.
+func NewRangeNode(tok *token.Token, low *Node, high *Node) *Node {
+	node := NewNode(Range)
+	node.Tok = tok
+	node.AppendNode(low, high)
+	return node
+}
+
+// NewFunctionNode returns a new function Node given the function keyword Token and an argument
+// expression Node. The operation is the tag of the keyword, ex. FLOAT, TRUNC, ORD, SUCC, PRED or
+// LENGTH.
+func NewFunctionNode(tok *token.Token, arg *Node) *Node {
+	node := NewNode(Function)
+	node.Op = tok.Tag
+	node.Tok = tok
+	node.AppendNode(arg)
+	return node
+}
+
+// NewIndexNode returns a new index Node given the left bracket Token, the Node of the indexed array
+// and an index expression Node. The array is either a terminal Node naming a variable or another
+// index Node, ex. m[i] in m[i][j].
+func NewIndexNode(tok *token.Token, array *Node, index *Node) *Node {
+	node := NewNode(Index)
+	node.Tok = tok
+	node.AppendNode(array, index)
+	return node
+}
+
+// NewConditionalNode returns a new conditional Node given the IF token, a condition Node and the
+// expression Nodes of the THEN and ELSE arms. The arms are the first two children so that they're
+// balanced like the two sides of a math Node, and the condition comes after them.
+func NewConditionalNode(tok *token.Token, cond *Node, then *Node, els *Node) *Node {
+	node := NewNode(Conditional)
+	node.Tok = tok
+	node.AppendNode(then, els, cond)
+	return node
+}
+
+// NewTerminalNode returns a new terminal Node given a terminal Token (Identifier, Integer, Real or
+// String).
 func NewTerminalNode(tok *token.Token) *Node {
 	node := NewNode(Terminal)
 	node.Tok = tok
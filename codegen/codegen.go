@@ -5,6 +5,10 @@ package codegen
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
 
 	"github.com/saicheems/simplelang/analyser"
 	"github.com/saicheems/simplelang/ast"
@@ -12,11 +16,40 @@ import (
 	"github.com/saicheems/simplelang/token"
 )
 
+// Integer arithmetic modes for Options.
+const (
+	DefaultArithmetic  = iota // add and sub trap on overflow, mult truncates.
+	WrappingArithmetic        // Every operator wraps around on overflow.
+	CheckedArithmetic         // Every operator reports overflow with the source line.
+)
+
+// Options controls optional parts of the generated code. The zero value is the default.
+type Options struct {
+	NoAsserts  bool // Strip ASSERT statements from the generated code.
+	Unsafe     bool // Omit runtime checks such as the division by zero check.
+	StackSize  int  // Stack size in bytes checked on procedure entry. 0 means no limit.
+	Arithmetic int  // One of the arithmetic modes defined by this package.
+}
+
 // CodeGenerator implements the code generation phase of the compilation.
 type CodeGenerator struct {
-	a     *analyser.Analyser
-	buf   *bytes.Buffer // Byte buffer for the output of the code generation.
-	count int           // Global label count: ensures labels are unique.
+	a       *analyser.Analyser
+	opt     Options         // Options for the generated code.
+	buf     *bytes.Buffer   // Byte buffer for the output of the code generation.
+	data    *bytes.Buffer   // Byte buffer for the data segment.
+	runtime map[string]bool // Runtime routines used by the generated code.
+	count   int             // Global label count: ensures labels are unique.
+	module  *ast.Node       // Program node of the module being generated, or nil for a program.
+	prefix  string          // Prefix of every label so that labels are unique across modules.
+	// Label of the state of the coroutine being generated, or an empty string outside coroutines.
+	coroutine  string
+	handlers   bool // Whether or not the generated code uses the exception handler chain.
+	processes  bool // Whether or not the generated code uses the scheduler.
+	preempt    bool // Whether or not loops are preemption points.
+	checkStack bool // Whether or not procedures check the stack against the limit on entry.
+	guard      int  // Size in bytes of the guard below the stack limit of a coroutine or process.
+	// Label of the empty STRING value in the data segment, or an empty string until it's needed.
+	emptyString string
 }
 
 // New returns a new Analyer that prints to the internal byte buffer.
@@ -24,12 +57,44 @@ func New(a *analyser.Analyser) *CodeGenerator {
 	c := new(CodeGenerator)
 	c.a = a
 	c.buf = bytes.NewBufferString("")
+	c.data = bytes.NewBufferString("")
+	c.runtime = make(map[string]bool)
 	return c
 }
 
-// String returns the contents of the CodeGenerator's buffer as a string.
+// SetOptions sets the options used for code generation. It should be called before Generate.
+func (c *CodeGenerator) SetOptions(opt Options) {
+	c.opt = opt
+}
+
+// String returns the contents of the CodeGenerator's buffer as a string. If anything was placed in
+// the data segment it follows the code. The code starts with a .text directive so that the output
+// of modules can be appended to the output of a program.
 func (c *CodeGenerator) String() string {
-	return c.buf.String()
+	if c.data.Len() == 0 {
+		return ".text\n" + c.buf.String()
+	}
+	return ".text\n" + c.buf.String() + ".data\n" + c.data.String()
+}
+
+// ModuleName returns the name of the module that was generated, or an empty string if a program was
+// generated.
+func (c *CodeGenerator) ModuleName() string {
+	if c.module == nil {
+		return ""
+	}
+	return c.module.Tok.Lex
+}
+
+// WriteInterface writes the interface file of the module that was generated to w. Programs that
+// import the module are analysed and generated with it.
+func (c *CodeGenerator) WriteInterface(w io.Writer) error {
+	exports := c.module.Children[2]
+	names := make([]string, 0, len(exports.Children))
+	for _, iden := range exports.Children {
+		names = append(names, iden.Tok.Lex)
+	}
+	return c.module.Children[0].Sym.WriteInterface(w, names)
 }
 
 // Generate uses the abstract syntax tree returned by the Analyser and begins code generation if the
@@ -47,19 +112,38 @@ func (c *CodeGenerator) Generate() {
 // definitions and places them at the head of the assembly. It also sets up the top level vars and
 // generates the top level statement.
 func (c *CodeGenerator) generateProgram(node *ast.Node) {
+	if node.Tok != nil {
+		c.generateModule(node)
+		return
+	}
 	bloc := node.Children[0]
-	vars := bloc.Children[1]
-	proc := bloc.Children[2]
-	stmt := bloc.Children[3]
+	proc := bloc.Children[4]
+	stmt := bloc.Children[5]
 
 	syms := []*symtable.SymbolTable{bloc.Sym}
+	// Only a program with a COBEGIN has processes to switch between.
+	c.preempt = hasTag(stmt, ast.Cobegin)
+	c.setStackCheck(bloc)
+	c.generateStatics(bloc, "main")
 	// We'll lay out the procedures first at the top of the assembly output.
 	c.generateProcedure(proc, syms)
 	c.emitLabel("main")
 	// Set up the current frame pointer.
 	c.emitMove("$fp", "$sp")
+	// Procedures check the stack against this limit on entry. Modules compiled with a stack size
+	// or with coroutines check it too, so a program that imports modules always has one. A limit of
+	// 0 never fails.
+	if c.checkStack || len(node.Children[1].Children) > 0 {
+		c.addWords(stackLimitLabel, 1)
+	}
+	if c.opt.StackSize > 0 {
+		c.emitSubUnsigned("$t0", "$sp", c.opt.StackSize)
+		c.emitLoadAddress("$t1", stackLimitLabel)
+		c.emitStoreWord("$t0", "$t1", 0)
+	}
 	// Load all the variables in this scope onto the current frame. Initialize to 0.
-	for i := 0; i < len(vars.Children); i++ {
+	numVars := c.frameWords(bloc)
+	for i := 0; i < numVars; i++ {
 		c.emitLoadInt("$a0", 0)
 		c.emitStoreWord("$a0", "$sp", 0)
 		c.emitSubUnsigned("$sp", "$sp", 4)
@@ -69,6 +153,47 @@ func (c *CodeGenerator) generateProgram(node *ast.Node) {
 	// Generate exit syscall at the end of the program.
 	c.emitLoadInt("$v0", 10)
 	c.emitSyscall()
+	// The runtime routines go after the exit so they're only reached with a jal.
+	c.generateRuntime()
+	// The program owns the exception handler chain, which the modules it imports may use too.
+	if c.handlers || len(node.Children[1].Children) > 0 {
+		c.addWords(handlerLabel, 1)
+	}
+	if c.processes || len(node.Children[1].Children) > 0 {
+		c.addSchedulerWords()
+	}
+}
+
+// generateModule begins generation at the program node of a module. Every label is prefixed with
+// the name of the module. The top level vars go in the data segment since there's no main frame to
+// keep them in. Exported variables and procedures are made global so other files can refer to them.
+func (c *CodeGenerator) generateModule(node *ast.Node) {
+	c.module = node
+	c.prefix = node.Tok.Lex + "_"
+	bloc := node.Children[0]
+	vars := bloc.Children[2]
+	proc := bloc.Children[4]
+
+	syms := []*symtable.SymbolTable{bloc.Sym}
+	c.setStackCheck(bloc)
+	for _, iden := range vars.Children {
+		value := bloc.Sym.Get(symtable.Key{symtable.Integer, iden.Tok.Lex})
+		value.Label = c.prefix + "var_" + iden.Tok.Lex
+		c.addWords(value.Label, value.Type.Size())
+	}
+	c.generateStatics(bloc, node.Tok.Lex)
+	c.generateProcedure(proc, syms)
+	for _, iden := range node.Children[2].Children {
+		for _, tag := range []int{symtable.Integer, symtable.Procedure} {
+			if value := bloc.Sym.Get(symtable.Key{tag, iden.Tok.Lex}); value != nil {
+				c.emitGlobal(value.Label)
+			}
+		}
+		if value := bloc.Sym.Get(symtable.Key{symtable.Procedure, iden.Tok.Lex}); value != nil {
+			c.generateExportStub(iden.Tok.Lex, value)
+		}
+	}
+	c.generateRuntime()
 }
 
 // generateProcedure begins generation of a procedure node. It generates the definition of the
@@ -76,31 +201,44 @@ func (c *CodeGenerator) generateProgram(node *ast.Node) {
 // call. That is left to be done at a CALL statement.
 func (c *CodeGenerator) generateProcedure(node *ast.Node, syms []*symtable.SymbolTable) {
 	for _, node := range node.Children {
+		if node.Tag == ast.Coroutine {
+			c.generateCoroutine(node, syms)
+			continue
+		}
+		// The code of an external procedure is somewhere else.
+		if node.Tag == ast.External {
+			continue
+		}
 		iden := node.Children[0]
 		bloc := node.Children[1]
-		// Find out how many variables we have so we can set up the activation record.
-		numVars := len(bloc.Children[1].Children)
+		// Find out how many words of variables we have so we can set up the activation record.
+		numVars := c.frameWords(bloc)
 		// Emit the procedure label.
 		label := c.getNewLabel("procedure")
 		c.emitLabel(label)
 		bodyLabel := label + "_body" // Label of the procedure body.
 		doneLabel := label + "_done" // Label of the procedure end.
+		if c.checkStack {
+			c.generateStackCheck(label, iden.Tok.Lex)
+		}
 		// Store the return address on the stack.
 		c.emitStoreWord("$ra", "$sp", 0)
 		c.emitSubUnsigned("$sp", "$sp", 4)
 
 		key := symtable.Key{symtable.Procedure, iden.Tok.Lex}
 		// Value includes the procedure label and how many arguments it has.
-		value := symtable.Value{label, 0, 0, numVars}
+		value := symtable.Value{Label: label, NumVars: numVars}
 		syms[len(syms)-1].Put(key, &value) // Write the value with the new info back.
 		// Jump to the body so we don't prematurely execute nested procedures.
 		c.emitJump(bodyLabel)
+		// The statics have to be placed before any nested procedure uses them.
+		c.generateStatics(bloc, label)
 		// Generate any nested procedures.
 		nestSyms := append(syms, bloc.Sym)
-		c.generateProcedure(bloc.Children[2], nestSyms)
+		c.generateProcedure(bloc.Children[4], nestSyms)
 		// Generate code for the body.
 		c.emitLabel(bodyLabel)
-		c.generateStatement(bloc.Children[3], nestSyms)
+		c.generateStatement(bloc.Children[5], nestSyms)
 		// Emit the done tag for the function.
 		c.emitLabel(doneLabel)
 		// Load the return address from the stack.
@@ -113,50 +251,143 @@ func (c *CodeGenerator) generateProcedure(node *ast.Node, syms []*symtable.Symbo
 	}
 }
 
+// generateCall emits a call of a procedure declared n scopes out. It sets up the activation record
+// of the procedure: the old frame pointer, the static link and the variables of the procedure.
+func (c *CodeGenerator) generateCall(n int, value *symtable.Value) {
+	// Store the old frame pointer on the stack..
+	c.emitStoreWord("$fp", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+	// Calculate the static link.
+	c.emitMove("$a0", "$fp") // Points to frame of main if we're at depth 0.
+	for i := 0; i < n; i++ {
+		c.emitLoadWord("$a0", "$a0", 4)
+	}
+	// Store the static link on the stack.
+	c.emitStoreWord("$a0", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+	// Have the new frame pointer point to the stack.
+	c.emitMove("$fp", "$sp")
+	// Load all the variables in this scope onto the current frame. Initialize to 0.
+	for i := 0; i < value.NumVars; i++ {
+		c.emitLoadInt("$a0", 0)
+		c.emitStoreWord("$a0", "$sp", 0)
+		c.emitSubUnsigned("$sp", "$sp", 4)
+	}
+	c.emitJumpAndLink(value.Label)
+}
+
+// generateExternalCall emits a call of an external procedure. The arguments are evaluated in order
+// and passed in $a0 to $a3, and the procedure is called with a jal. It leaves its result in $v0 and
+// must give back $sp, $fp and the stack above $sp the way it found them.
+func (c *CodeGenerator) generateExternalCall(node *ast.Node, value *symtable.Value,
+	syms []*symtable.SymbolTable) {
+	args := node.Children[1:]
+	for _, arg := range args {
+		c.generateExpression(arg, syms)
+	}
+	// Pop the arguments off of the stack, last one first.
+	for i := len(args) - 1; i >= 0; i-- {
+		c.emitAddUnsigned("$sp", "$sp", 4)
+		c.emitLoadWord(fmt.Sprintf("$a%d", i), "$sp", 0)
+	}
+	c.emitJumpAndLink(value.Label)
+}
+
+// generateExportStub emits the global entry point of an exported procedure of a module. It's named
+// after the module and the procedure (ex. calc_export_gcd) and is called like an external
+// procedure, so hand written assembly can call the procedure without setting up its activation
+// record.
+func (c *CodeGenerator) generateExportStub(name string, value *symtable.Value) {
+	label := c.prefix + "export_" + name
+	c.emitGlobal(label)
+	c.emitLabel(label)
+	c.emitStoreWord("$ra", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+	// Procedures of a module keep their variables in the data segment, so they don't follow the
+	// static link.
+	c.generateCall(0, value)
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$ra", "$sp", 0)
+	c.emitJumpReturn()
+}
+
+// generateStatics places the statics of a block in the data segment. Each static gets a label made
+// from the base label of the block (ex. the procedure label) so it is unique to the block. The
+// label is written to the symbol table of the block.
+func (c *CodeGenerator) generateStatics(bloc *ast.Node, base string) {
+	for _, iden := range bloc.Children[3].Children {
+		value := bloc.Sym.Get(symtable.Key{symtable.Integer, iden.Tok.Lex})
+		value.Label = base + "_static_" + iden.Tok.Lex
+		c.addWords(value.Label, value.Type.Size())
+	}
+}
+
+// generateStackCheck emits a check that the stack pointer hasn't gone past the stack limit. If it
+// has, the program reports the stack overflow in the named procedure and exits.
+func (c *CodeGenerator) generateStackCheck(label string, name string) {
+	okLabel := label + "_stack"
+	c.emitLoadAddress("$t0", stackLimitLabel)
+	c.emitLoadWord("$t0", "$t0", 0)
+	c.emitSetOnLessThanUnsigned("$t0", "$sp", "$t0")
+	c.emitBranchOnEqual("$t0", "$zero", okLabel)
+	c.emitLoadAddress("$a0", c.addString("stack overflow in procedure "+name))
+	c.emitLoadInt("$a2", 1)
+	c.emitJump(c.useRuntime(runtimeAbort))
+	c.emitLabel(okLabel)
+}
+
 // generateStatement begins generation of a statement node. It generates assignments, procedure
 // calls, if thens, while dos, and print statements.
 func (c *CodeGenerator) generateStatement(node *ast.Node, syms []*symtable.SymbolTable) {
 	switch node.Tag {
 	case ast.Assignment:
 		iden := node.Children[0]
-		key := symtable.Key{symtable.Integer, iden.Tok.Lex}
-		// Find out where/how far up the left hand side was defined.
-		n, value := c.getValueFromClosestSymbolTable(key, syms)
-
-		// Rest of stuff.
+		if iden.Type != nil && iden.Type.Kind == symtable.ArrayKind {
+			c.generateArrayCopy(node, syms)
+			return
+		}
 		c.generateExpression(node.Children[1], syms)
+		// Indicates which variable or element corresponds to the left hand side.
+		c.loadAddressOfDesignator("$t0", iden, syms)
 		c.emitAddUnsigned("$sp", "$sp", 4)
 		c.emitLoadWord("$a0", "$sp", 0) // Load result onto $a0
-		// Indicates which variable on the frame corresponds to the left hand side.
-		c.loadAddressOfPreviousRecord("$t0", n, value.Order)
 		c.emitStoreWord("$a0", "$t0", 0)
+	case ast.CompoundAssignment:
+		iden := node.Children[0]
+		c.generateExpression(node.Children[1], syms)
+		// Walk the activation records once and use the address for both the load and the store.
+		c.loadAddressOfDesignator("$t2", iden, syms)
+		c.emitAddUnsigned("$sp", "$sp", 4)
+		c.emitLoadWord("$t1", "$sp", 0) // Load the right hand side onto $t1.
+		c.emitLoadWord("$t0", "$t2", 0)
+		if iden.Type == symtable.RealType {
+			c.generateRealOperation(node.Op, "$t0", "$t0", "$t1")
+		} else if iden.Type == symtable.SetType {
+			c.generateSetOperation(node.Op, "$t0", "$t0", "$t1")
+		} else if iden.Type == symtable.StringType {
+			c.generateEmptyStringCheck("$t0")
+			// The routine clobbers the $t registers, so the address is kept on the stack.
+			c.emitStoreWord("$t2", "$sp", 0)
+			c.emitSubUnsigned("$sp", "$sp", 4)
+			c.emitMove("$a0", "$t0")
+			c.emitMove("$a1", "$t1")
+			c.emitJumpAndLink(c.useRuntime(runtimeConcat))
+			c.emitMove("$t0", "$v0")
+			c.emitAddUnsigned("$sp", "$sp", 4)
+			c.emitLoadWord("$t2", "$sp", 0)
+		} else {
+			c.generateOperation(node.Op, node.Tok, "$t0", "$t0", "$t1")
+		}
+		c.emitStoreWord("$t0", "$t2", 0)
 	case ast.Call:
 		iden := node.Children[0]
 		key := symtable.Key{symtable.Procedure, iden.Tok.Lex}
 		n, value := c.getValueFromClosestSymbolTable(key, syms)
-
-		label := value.Label
-		numVars := value.NumVars
-		// Store the old frame pointer on the stack..
-		c.emitStoreWord("$fp", "$sp", 0)
-		c.emitSubUnsigned("$sp", "$sp", 4)
-		// Calculate the static link.
-		c.emitMove("$a0", "$fp") // Points to frame of main if we're at depth 0.
-		for i := 0; i < n; i++ {
-			c.emitLoadWord("$a0", "$a0", 4)
+		if value.External {
+			c.generateExternalCall(node, value, syms)
+			return
 		}
-		// Store the static link on the stack.
-		c.emitStoreWord("$a0", "$sp", 0)
-		c.emitSubUnsigned("$sp", "$sp", 4)
-		// Have the new frame pointer point to the stack.
-		c.emitMove("$fp", "$sp")
-		// Load all the variables in this scope onto the current frame. Initialize to 0.
-		for i := 0; i < numVars; i++ {
-			c.emitLoadInt("$a0", 0)
-			c.emitStoreWord("$a0", "$sp", 0)
-			c.emitSubUnsigned("$sp", "$sp", 4)
-		}
-		c.emitJumpAndLink(label)
+		c.generateCall(n, value)
 	case ast.Begin:
 		// Generate any statements under the begin. Retains same lexical scope.
 		for _, node := range node.Children {
@@ -186,21 +417,109 @@ func (c *CodeGenerator) generateStatement(node *ast.Node, syms []*symtable.Symbo
 		c.emitJump(doneLabel)
 		c.emitLabel(doLabel)
 		c.generateStatement(stmt, syms)
+		if c.preempt {
+			c.generatePreemptionPoint()
+		}
 		// Jump to the beginning of the while loop.
 		c.emitJump(label)
 		c.emitLabel(doneLabel)
+	case ast.For:
+		c.generateFor(node, syms)
+	case ast.Case:
+		c.generateCase(node, syms)
 	case ast.Print:
 		expr := node.Children[0]
 		c.generateExpression(expr, syms)
 		// Pop result off of the stack.
 		c.emitAddUnsigned("$sp", "$sp", 4)
 		c.emitLoadWord("$a0", "$sp", 0)
-		// Emit syscalls to print an integer and a newline.
-		c.emitLoadInt("$v0", 1)
-		c.emitSyscall()
+		// Emit syscalls to print the number and a newline.
+		c.generatePrintNumber(expr.Type)
 		c.emitLoadInt("$a0", 10) // Prints newline character.
 		c.emitLoadInt("$v0", 11)
 		c.emitSyscall()
+	case ast.Assert:
+		if c.opt.NoAsserts {
+			return
+		}
+		label := c.getNewLabel("assert")
+		doneLabel := label + "_done"
+		c.generateCondition(node.Children[0], doneLabel, syms)
+		// The condition is false. Exit with the code if there is one, otherwise with 1. A code of 0
+		// would report success, so it's exited with as 1 too.
+		if len(node.Children) > 1 {
+			c.generateExpression(node.Children[1], syms)
+			c.emitAddUnsigned("$sp", "$sp", 4)
+			c.emitLoadWord("$a2", "$sp", 0)
+			c.emitBranchNotEqual("$a2", "$zero", label)
+			c.emitLoadInt("$a2", 1)
+			c.emitLabel(label)
+		} else {
+			c.emitLoadInt("$a2", 1)
+		}
+		c.generateFail("assertion failed", node.Tok)
+		c.emitLabel(doneLabel)
+	case ast.Try:
+		c.generateTry(node, syms)
+	case ast.Asm:
+		c.generateAsm(node, syms)
+	case ast.Cobegin:
+		c.generateCobegin(node, syms)
+	case ast.Wait:
+		c.generateWait(node, syms)
+	case ast.Signal:
+		c.generateSignal(node, syms)
+	case ast.Yield:
+		c.generateExpression(node.Children[0], syms)
+		c.emitAddUnsigned("$sp", "$sp", 4)
+		c.emitLoadWord("$v0", "$sp", 0)
+		resumeLabel := c.getNewLabel("yield")
+		c.emitLoadAddress("$t0", c.coroutine)
+		c.emitStoreWord("$sp", "$t0", 4*stateSP)
+		c.emitStoreWord("$fp", "$t0", 4*stateFP)
+		c.emitLoadAddress("$t1", resumeLabel)
+		c.emitStoreWord("$t1", "$t0", 4*stateResume)
+		c.emitLoadInt("$t1", coroutineSuspended)
+		c.emitStoreWord("$t1", "$t0", 4*stateStatus)
+		c.generateSwitchToResumer()
+		c.emitLabel(resumeLabel)
+	case ast.Raise:
+		c.handlers = true
+		c.generateExpression(node.Children[0], syms)
+		c.emitAddUnsigned("$sp", "$sp", 4)
+		c.emitLoadWord("$a0", "$sp", 0)
+		c.emitLoadInt("$a1", node.Tok.Ln+1)
+		c.emitJump(c.useRuntime(runtimeRaise))
+	case ast.Halt:
+		if len(node.Children) > 0 {
+			c.generateExpression(node.Children[0], syms)
+			c.emitAddUnsigned("$sp", "$sp", 4)
+			c.emitLoadWord("$a0", "$sp", 0)
+		} else {
+			c.emitLoadInt("$a0", 0)
+		}
+		c.emitLoadInt("$v0", 17) // exit2 syscall.
+		c.emitSyscall()
+	case ast.Open:
+		c.generateOpen(node, syms)
+	case ast.Close:
+		c.generateClose(node, syms)
+	case ast.Read:
+		c.generateRead(node, syms)
+	case ast.Write:
+		// The analyser puts the file first if the statement writes to one.
+		if len(node.Children) > 0 && node.Children[0].Tag == ast.Terminal {
+			c.generateFileWrite(node, syms)
+			return
+		}
+		for _, node := range node.Children {
+			c.generateFormat(node, syms)
+		}
+		if node.Op == token.Writeln {
+			c.emitLoadInt("$a0", 10) // Prints newline character.
+			c.emitLoadInt("$v0", 11)
+			c.emitSyscall()
+		}
 	default:
 		// This can't possibly happen...
 		fmt.Println("A terrible error occurred.",
@@ -208,6 +527,134 @@ func (c *CodeGenerator) generateStatement(node *ast.Node, syms []*symtable.Symbo
 	}
 }
 
+// generateFor emits a for statement. The limit is evaluated once and kept on the stack while the
+// loop runs. The loop stops when the control variable reaches the limit instead of when it passes
+// it, so stepping the variable can't overflow.
+func (c *CodeGenerator) generateFor(node *ast.Node, syms []*symtable.SymbolTable) {
+	init := node.Children[0]
+	iden := init.Children[0]
+	label := c.getNewLabel("for")
+	doneLabel := label + "_done"
+	op := token.Plus
+	if node.Op == token.Downto {
+		op = token.Minus
+	}
+	tok := &token.Token{Tag: token.Integer, Val: 1, Ln: node.Tok.Ln, File: node.Tok.File}
+	one := ast.NewTerminalNode(tok)
+	one.Type = symtable.IntegerType
+	step := ast.NewCompoundAssignmentNode(op, node.Tok, iden, one)
+
+	c.generateStatement(init, syms)
+	c.generateExpression(node.Children[1], syms)
+	c.emitLabel(label)
+	// Skip the body if the variable is past the limit.
+	c.generateExpression(iden, syms)
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t0", "$sp", 0)
+	c.emitLoadWord("$t1", "$sp", 4)
+	if node.Op == token.To {
+		c.emitSetOnLessThan("$t2", "$t1", "$t0")
+	} else {
+		c.emitSetOnLessThan("$t2", "$t0", "$t1")
+	}
+	c.emitBranchNotEqual("$t2", "$zero", doneLabel)
+	c.generateStatement(node.Children[2], syms)
+	// Stop at the limit, otherwise step the variable towards it.
+	c.generateExpression(iden, syms)
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t0", "$sp", 0)
+	c.emitLoadWord("$t1", "$sp", 4)
+	c.emitBranchOnEqual("$t0", "$t1", doneLabel)
+	c.generateStatement(step, syms)
+	c.emitJump(label)
+	c.emitLabel(doneLabel)
+	// Pop the limit off of the stack.
+	c.emitAddUnsigned("$sp", "$sp", 4)
+}
+
+// generateCase emits a case statement. The selector is compared with the labels of each arm in
+// turn and the statement of the first arm with a matching label runs. Nothing runs if no label
+// matches.
+func (c *CodeGenerator) generateCase(node *ast.Node, syms []*symtable.SymbolTable) {
+	label := c.getNewLabel("case")
+	doneLabel := label + "_done"
+	arms := node.Children[1:]
+
+	c.generateExpression(node.Children[0], syms)
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t0", "$sp", 0)
+	for i, arm := range arms {
+		for _, val := range arm.Children[1:] {
+			c.emitLoadInt("$t1", val.Tok.Val)
+			c.emitBranchOnEqual("$t0", "$t1", fmt.Sprintf("%s_arm%d", label, i))
+		}
+	}
+	c.emitJump(doneLabel)
+	for i, arm := range arms {
+		c.emitLabel(fmt.Sprintf("%s_arm%d", label, i))
+		c.generateStatement(arm.Children[0], syms)
+		c.emitJump(doneLabel)
+	}
+	c.emitLabel(doneLabel)
+}
+
+// generateFormat begins generation of a single item of a write statement. Items without a width
+// are printed directly with a syscall. Items with a width are passed to a runtime routine which
+// pads them on the left with spaces.
+func (c *CodeGenerator) generateFormat(node *ast.Node, syms []*symtable.SymbolTable) {
+	item := node.Children[0]
+	isString := item.Tag == ast.Terminal && item.Tok.Tag == token.String
+	if !isString {
+		c.generateExpression(item, syms)
+	}
+	if len(node.Children) > 1 {
+		c.generateExpression(node.Children[1], syms)
+		// Pop the width off of the stack.
+		c.emitAddUnsigned("$sp", "$sp", 4)
+		c.emitLoadWord("$a1", "$sp", 0)
+	}
+	if isString {
+		c.emitLoadAddress("$a0", c.addString(item.Tok.Lex))
+	} else {
+		// Pop the value off of the stack.
+		c.emitAddUnsigned("$sp", "$sp", 4)
+		c.emitLoadWord("$a0", "$sp", 0)
+	}
+	if item.Type == symtable.StringType {
+		// Skip the length to get to the null terminated characters.
+		c.emitAddUnsigned("$a0", "$a0", 4)
+		isString = true
+	}
+	if len(node.Children) > 1 {
+		routine := runtimeWriteInt
+		if isString {
+			routine = runtimeWriteString
+		}
+		c.emitJumpAndLink(c.useRuntime(routine))
+		return
+	}
+	if isString {
+		c.emitLoadInt("$v0", 4)
+		c.emitSyscall()
+	} else {
+		c.generatePrintNumber(item.Type)
+	}
+}
+
+// generatePrintNumber emits a syscall to print the number in $a0 given its type. Reals are moved to
+// $f12 to be printed and characters are printed as the character rather than its code.
+func (c *CodeGenerator) generatePrintNumber(typ *symtable.Type) {
+	if typ == symtable.RealType {
+		c.emitMoveToCoprocessor("$a0", "$f12")
+		c.emitLoadInt("$v0", 2)
+	} else if typ == symtable.CharType {
+		c.emitLoadInt("$v0", 11)
+	} else {
+		c.emitLoadInt("$v0", 1)
+	}
+	c.emitSyscall()
+}
+
 // generateConditiont begins generation of a condition node. It evaluates the two expressions on
 // either side of the condition and compares them with the appropriate branch command. If the
 // condition returns true, then the code resumes at the specified label. Otherwise, it continues at
@@ -230,23 +677,111 @@ func (c *CodeGenerator) generateCondition(node *ast.Node, label string,
 	c.emitLoadWord("$t0", "$sp", 0)
 	c.emitAddUnsigned("$sp", "$sp", 4)
 	c.emitLoadWord("$t1", "$sp", 0)
+	if node.Op == token.In {
+		c.generateMembership(label)
+		return
+	} else if node.Children[0].Type == symtable.RealType {
+		c.generateRealComparison(node.Op, label)
+		return
+	} else if node.Children[0].Type == symtable.SetType {
+		c.generateSetComparison(node.Op, label)
+		return
+	} else if node.Children[0].Type == symtable.StringType {
+		// Strings are compared like the -1, 0 or 1 the routine gives is compared with 0.
+		c.emitMove("$a0", "$t1")
+		c.emitMove("$a1", "$t0")
+		c.emitJumpAndLink(c.useRuntime(runtimeCompare))
+		c.emitMove("$t1", "$v0")
+		c.emitMove("$t0", "$zero")
+	}
 	switch node.Op {
 	case token.Equals:
 		c.emitBranchOnEqual("$t0", "$t1", label)
 	case token.NotEquals:
 		c.emitBranchNotEqual("$t0", "$t1", label)
+	// The comparisons use slt rather than a subtraction so they can't overflow. $t1 holds the left
+	// hand side and $t0 the right hand side.
 	case token.LessThan:
-		c.emitSub("$t0", "$t0", "$t1")
-		c.emitBranchOnGreaterThanZero("$t0", label)
+		c.emitSetOnLessThan("$t0", "$t1", "$t0")
+		c.emitBranchNotEqual("$t0", "$zero", label)
+	case token.GreaterThan:
+		c.emitSetOnLessThan("$t0", "$t0", "$t1")
+		c.emitBranchNotEqual("$t0", "$zero", label)
+	case token.LessThanEqualTo:
+		c.emitSetOnLessThan("$t0", "$t0", "$t1")
+		c.emitBranchOnEqual("$t0", "$zero", label)
+	case token.GreaterThanEqualTo:
+		c.emitSetOnLessThan("$t0", "$t1", "$t0")
+		c.emitBranchOnEqual("$t0", "$zero", label)
+	default:
+		// This can't possibly happen...
+		fmt.Println("A terrible error occurred.",
+			"The abstract syntax tree is wrong and I'm generating code...")
+	}
+}
+
+// generateRealComparison compares the real in $t1 (the left hand side) with the real in $t0 (the
+// right hand side) and jumps to the label if the comparison is true.
+func (c *CodeGenerator) generateRealComparison(op int, label string) {
+	c.emitMoveToCoprocessor("$t1", "$f0")
+	c.emitMoveToCoprocessor("$t0", "$f2")
+	switch op {
+	case token.Equals:
+		c.emitFloatCompare("eq", "$f0", "$f2")
+		c.emitBranchOnFloatTrue(label)
+	case token.NotEquals:
+		c.emitFloatCompare("eq", "$f0", "$f2")
+		c.emitBranchOnFloatFalse(label)
+	case token.LessThan:
+		c.emitFloatCompare("lt", "$f0", "$f2")
+		c.emitBranchOnFloatTrue(label)
 	case token.GreaterThan:
-		c.emitSub("$t0", "$t1", "$t0")
-		c.emitBranchOnGreaterThanZero("$t1", label)
+		c.emitFloatCompare("lt", "$f2", "$f0")
+		c.emitBranchOnFloatTrue(label)
+	case token.LessThanEqualTo:
+		c.emitFloatCompare("le", "$f0", "$f2")
+		c.emitBranchOnFloatTrue(label)
+	case token.GreaterThanEqualTo:
+		c.emitFloatCompare("le", "$f2", "$f0")
+		c.emitBranchOnFloatTrue(label)
+	default:
+		// This can't possibly happen...
+		fmt.Println("A terrible error occurred.",
+			"The abstract syntax tree is wrong and I'm generating code...")
+	}
+}
+
+// generateMembership tests whether the integer in $t1 is in the set in $t0 and jumps to the label
+// if it is. Integers outside of 0 to 31 aren't in any set.
+func (c *CodeGenerator) generateMembership(label string) {
+	outLabel := c.getNewLabel("in")
+	c.emitLoadInt("$t2", 32)
+	c.emitSetOnLessThanUnsigned("$t2", "$t1", "$t2")
+	c.emitBranchOnEqual("$t2", "$zero", outLabel)
+	c.emitShiftRightLogicalVariable("$t0", "$t0", "$t1")
+	c.emitAndImmediate("$t0", "$t0", 1)
+	c.emitBranchNotEqual("$t0", "$zero", label)
+	c.emitLabel(outLabel)
+}
+
+// generateSetComparison compares the set in $t1 (the left hand side) with the set in $t0 (the right
+// hand side) and jumps to the label if the comparison is true. <= tests whether the left hand side
+// is a subset of the right hand side and >= whether it is a superset.
+func (c *CodeGenerator) generateSetComparison(op int, label string) {
+	switch op {
+	case token.Equals:
+		c.emitBranchOnEqual("$t0", "$t1", label)
+	case token.NotEquals:
+		c.emitBranchNotEqual("$t0", "$t1", label)
 	case token.LessThanEqualTo:
-		c.emitSub("$t0", "$t0", "$t1")
-		c.emitBranchOnGreaterThanOrEqualZero("$t0", label)
+		// It's a subset if nothing is left over once the right hand side is taken out.
+		c.emitNor("$t0", "$t0", "$zero")
+		c.emitAnd("$t0", "$t1", "$t0")
+		c.emitBranchOnEqual("$t0", "$zero", label)
 	case token.GreaterThanEqualTo:
-		c.emitSub("$t0", "$t1", "$t0")
-		c.emitBranchOnGreaterThanOrEqualZero("$t0", label)
+		c.emitNor("$t1", "$t1", "$zero")
+		c.emitAnd("$t0", "$t0", "$t1")
+		c.emitBranchOnEqual("$t0", "$zero", label)
 	default:
 		// This can't possibly happen...
 		fmt.Println("A terrible error occurred.",
@@ -271,9 +806,12 @@ func (c *CodeGenerator) generateExpression(node *ast.Node, syms []*symtable.Symb
 				c.emitSubUnsigned("$sp", "$sp", 4)
 				return
 			}
-			// Load the identifier from the correct activation record.
-			c.loadAddressOfPreviousRecord("$a0", n, value.Order)
+			// Load the identifier from the correct activation record or the data segment.
+			c.loadAddressOfVariable("$a0", n, value)
 			c.emitLoadWord("$a0", "$a0", 0)
+			if value.Type == symtable.StringType {
+				c.generateEmptyStringCheck("$a0")
+			}
 			c.emitStoreWord("$a0", "$sp", 0)
 			c.emitSubUnsigned("$sp", "$sp", 4)
 		} else if node.Tok.Tag == token.Integer {
@@ -281,6 +819,15 @@ func (c *CodeGenerator) generateExpression(node *ast.Node, syms []*symtable.Symb
 			c.emitLoadInt("$a0", node.Tok.Val)
 			c.emitStoreWord("$a0", "$sp", 0)
 			c.emitSubUnsigned("$sp", "$sp", 4)
+		} else if node.Tok.Tag == token.Real {
+			// Reals are loaded as the bits of a single precision float.
+			c.emitLoadInt("$a0", int(int32(math.Float32bits(float32(node.Tok.Rval)))))
+			c.emitStoreWord("$a0", "$sp", 0)
+			c.emitSubUnsigned("$sp", "$sp", 4)
+		} else if node.Tok.Tag == token.String {
+			c.emitLoadAddress("$a0", c.addStringValue(node.Tok.Lex))
+			c.emitStoreWord("$a0", "$sp", 0)
+			c.emitSubUnsigned("$sp", "$sp", 4)
 		} else {
 			// This can't possibly happen...
 			fmt.Println("A terrible error occurred.",
@@ -288,6 +835,51 @@ func (c *CodeGenerator) generateExpression(node *ast.Node, syms []*symtable.Symb
 		}
 		return
 	}
+	if node.Tag == ast.Function {
+		c.generateFunction(node, syms)
+		return
+	} else if node.Tag == ast.Set {
+		c.generateSet(node, syms)
+		return
+	} else if node.Tag == ast.Index {
+		if node.Children[0].Type == symtable.StringType {
+			c.generateCharacter(node, syms)
+			return
+		}
+		c.loadAddressOfDesignator("$a0", node, syms)
+		c.emitLoadWord("$a0", "$a0", 0)
+		if node.Type == symtable.StringType {
+			c.generateEmptyStringCheck("$a0")
+		}
+		c.emitStoreWord("$a0", "$sp", 0)
+		c.emitSubUnsigned("$sp", "$sp", 4)
+		return
+	} else if node.Tag == ast.Call {
+		iden := node.Children[0]
+		_, value := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Procedure, iden.Tok.Lex},
+			syms)
+		c.generateExternalCall(node, value, syms)
+		c.emitStoreWord("$v0", "$sp", 0)
+		c.emitSubUnsigned("$sp", "$sp", 4)
+		return
+	} else if node.Tag == ast.Conditional {
+		c.generateConditional(node, syms)
+		return
+	} else if node.Tag == ast.Resume {
+		iden := node.Children[0]
+		key := symtable.Key{symtable.Coroutine, iden.Tok.Lex}
+		n, value := c.getValueFromClosestSymbolTable(key, syms)
+		// The static link is only used the first time the coroutine is resumed.
+		c.emitMove("$a0", "$fp")
+		for i := 0; i < n; i++ {
+			c.emitLoadWord("$a0", "$a0", 4)
+		}
+		c.emitLoadInt("$a1", node.Tok.Ln+1)
+		c.emitJumpAndLink(value.Label)
+		c.emitStoreWord("$v0", "$sp", 0)
+		c.emitSubUnsigned("$sp", "$sp", 4)
+		return
+	}
 	left := node.Children[0]
 	right := node.Children[1]
 	c.generateExpression(left, syms)
@@ -297,17 +889,72 @@ func (c *CodeGenerator) generateExpression(node *ast.Node, syms []*symtable.Symb
 	c.emitLoadWord("$t0", "$sp", 0)
 	c.emitAddUnsigned("$sp", "$sp", 4)
 	c.emitLoadWord("$t1", "$sp", 0)
-	if node.Op == token.Plus {
-		c.emitAdd("$t0", "$t0", "$t1")
-	} else if node.Op == token.Minus {
-		c.emitSub("$t0", "$t1", "$t0")
-	} else if node.Op == token.Times {
-		c.emitMul("$t0", "$t1")
-		c.emitMoveFromLo("$t0")
-	} else if node.Op == token.Divide {
-		c.emitDiv("$t1", "$t0")
-		c.emitMoveFromLo("$t0")
+	if node.Type == symtable.RealType {
+		c.generateRealOperation(node.Op, "$t0", "$t1", "$t0")
+	} else if node.Type == symtable.SetType {
+		c.generateSetOperation(node.Op, "$t0", "$t1", "$t0")
+	} else if node.Type == symtable.StringType {
+		// + is the only operation on strings.
+		c.emitMove("$a0", "$t1")
+		c.emitMove("$a1", "$t0")
+		c.emitJumpAndLink(c.useRuntime(runtimeConcat))
+		c.emitMove("$t0", "$v0")
 	} else {
+		if node.Op == token.Divide && !c.opt.Unsafe && !c.isConstant(right, syms) {
+			c.generateDivisionCheck("$t0", node.Tok)
+		}
+		c.generateOperation(node.Op, node.Tok, "$t0", "$t1", "$t0")
+	}
+	// Store the result on the stack.
+	c.emitStoreWord("$t0", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+}
+
+// generateConditional begins generation of a conditional node. Only the arm chosen by the condition
+// is evaluated, and either way it leaves a single value on the stack.
+func (c *CodeGenerator) generateConditional(node *ast.Node, syms []*symtable.SymbolTable) {
+	label := c.getNewLabel("conditional")
+	doneLabel := label + "_done"
+	c.generateCondition(node.Children[2], label, syms)
+	// The condition is false, so evaluate the ELSE arm.
+	c.generateExpression(node.Children[1], syms)
+	c.emitJump(doneLabel)
+	c.emitLabel(label)
+	c.generateExpression(node.Children[0], syms)
+	c.emitLabel(doneLabel)
+}
+
+// generateFunction begins generation of a function node. It evaluates the argument, applies the
+// function and places the result on the stack. ORD doesn't need to do anything since enumerations
+// are held as their position.
+func (c *CodeGenerator) generateFunction(node *ast.Node, syms []*symtable.SymbolTable) {
+	c.generateExpression(node.Children[0], syms)
+	// Pop the argument off of the stack.
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t0", "$sp", 0)
+	switch node.Op {
+	case token.Float:
+		c.emitMoveToCoprocessor("$t0", "$f0")
+		c.emitFloatInstruction("cvt.s.w", "$f0", "$f0")
+		c.emitMoveFromCoprocessor("$t0", "$f0")
+	case token.Trunc:
+		c.emitMoveToCoprocessor("$t0", "$f0")
+		c.emitFloatInstruction("trunc.w.s", "$f0", "$f0")
+		c.emitMoveFromCoprocessor("$t0", "$f0")
+	case token.Ord:
+	case token.Length:
+		c.emitLoadWord("$t0", "$t0", 0)
+	case token.Succ, token.Pred:
+		op := token.Plus
+		if node.Op == token.Pred {
+			op = token.Minus
+		}
+		c.emitLoadInt("$t1", 1)
+		c.generateOperation(op, node.Tok, "$t0", "$t0", "$t1")
+		if node.Type.Kind == symtable.EnumKind && !c.opt.Unsafe {
+			c.generateRangeCheck("$t0", len(node.Type.Values), node.Tok)
+		}
+	default:
 		// This can't possibly happen...
 		fmt.Println("A terrible error occurred.",
 			"The abstract syntax tree is wrong and I'm generating code...")
@@ -317,6 +964,386 @@ func (c *CodeGenerator) generateExpression(node *ast.Node, syms []*symtable.Symb
 	c.emitSubUnsigned("$sp", "$sp", 4)
 }
 
+// generateSet begins generation of a set node. It starts with an empty set on the stack and adds
+// each element to it. Elements that aren't constants are checked to be from 0 to 31 at runtime.
+func (c *CodeGenerator) generateSet(node *ast.Node, syms []*symtable.SymbolTable) {
+	c.emitStoreWord("$zero", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+	for _, elem := range node.Children {
+		if elem.Tag == ast.Range {
+			low := elem.Children[0]
+			high := elem.Children[1]
+			c.generateExpression(low, syms)
+			c.generateExpression(high, syms)
+			// Pop the bounds off of the stack.
+			c.emitAddUnsigned("$sp", "$sp", 4)
+			c.emitLoadWord("$t1", "$sp", 0)
+			c.emitAddUnsigned("$sp", "$sp", 4)
+			c.emitLoadWord("$t0", "$sp", 0)
+			c.generateElementCheck("$t0", low, syms)
+			c.generateElementCheck("$t1", high, syms)
+			// Keep the bits from the low bound up and the bits from the high bound down. If the low
+			// bound is above the high bound nothing is kept.
+			c.emitLoadInt("$t2", -1)
+			c.emitShiftLeftLogicalVariable("$t0", "$t2", "$t0")
+			c.emitLoadInt("$t3", 31)
+			c.emitSubUnsignedRegister("$t3", "$t3", "$t1")
+			c.emitShiftRightLogicalVariable("$t1", "$t2", "$t3")
+			c.emitAnd("$t0", "$t0", "$t1")
+		} else {
+			c.generateExpression(elem, syms)
+			// Pop the element off of the stack.
+			c.emitAddUnsigned("$sp", "$sp", 4)
+			c.emitLoadWord("$t0", "$sp", 0)
+			c.generateElementCheck("$t0", elem, syms)
+			c.emitLoadInt("$t1", 1)
+			c.emitShiftLeftLogicalVariable("$t0", "$t1", "$t0")
+		}
+		// Add the element to the set on the stack.
+		c.emitLoadWord("$t1", "$sp", 4)
+		c.emitOr("$t0", "$t0", "$t1")
+		c.emitStoreWord("$t0", "$sp", 4)
+	}
+}
+
+// generateElementCheck emits a check that the set element in register t is from 0 to 31 unless the
+// element is a constant (which the analyser has already checked) or the code is unsafe.
+func (c *CodeGenerator) generateElementCheck(t string, node *ast.Node,
+	syms []*symtable.SymbolTable) {
+	if !c.opt.Unsafe && !c.isConstant(node, syms) {
+		c.generateRangeCheck(t, 32, node.Tok)
+	}
+}
+
+// generateSetOperation emits the instructions for a single operation on sets. + is the union, * is
+// the intersection and - is the difference. $d = $s op $t; It may clobber $t3.
+func (c *CodeGenerator) generateSetOperation(op int, d string, s string, t string) {
+	if op == token.Plus {
+		c.emitOr(d, s, t)
+	} else if op == token.Times {
+		c.emitAnd(d, s, t)
+	} else if op == token.Minus {
+		c.emitNor("$t3", t, "$zero")
+		c.emitAnd(d, s, "$t3")
+	} else {
+		// This can't possibly happen...
+		fmt.Println("A terrible error occurred.",
+			"The abstract syntax tree is wrong and I'm generating code...")
+	}
+}
+
+// generateRealOperation emits the instructions for a single arithmetic operation on reals using the
+// floating point coprocessor. $d = $s op $t; It clobbers $f0 and $f2.
+func (c *CodeGenerator) generateRealOperation(op int, d string, s string, t string) {
+	c.emitMoveToCoprocessor(s, "$f0")
+	c.emitMoveToCoprocessor(t, "$f2")
+	if op == token.Plus {
+		c.emitFloatInstruction("add.s", "$f0", "$f0", "$f2")
+	} else if op == token.Minus {
+		c.emitFloatInstruction("sub.s", "$f0", "$f0", "$f2")
+	} else if op == token.Times {
+		c.emitFloatInstruction("mul.s", "$f0", "$f0", "$f2")
+	} else if op == token.Divide {
+		c.emitFloatInstruction("div.s", "$f0", "$f0", "$f2")
+	} else {
+		// This can't possibly happen...
+		fmt.Println("A terrible error occurred.",
+			"The abstract syntax tree is wrong and I'm generating code...")
+	}
+	c.emitMoveFromCoprocessor(d, "$f0")
+}
+
+// generateOperation emits the instructions for a single arithmetic operation. $d = $s op $t; The
+// token locates the operation for runtime errors. How overflow is handled depends on the arithmetic
+// mode. It may clobber $t3 to $t5.
+func (c *CodeGenerator) generateOperation(op int, tok *token.Token, d string, s string, t string) {
+	if c.opt.Arithmetic == CheckedArithmetic {
+		c.generateCheckedOperation(op, tok, d, s, t)
+		return
+	}
+	if c.opt.Arithmetic == WrappingArithmetic && op == token.Plus {
+		c.emitAddUnsignedRegister(d, s, t)
+	} else if c.opt.Arithmetic == WrappingArithmetic && op == token.Minus {
+		c.emitSubUnsignedRegister(d, s, t)
+	} else if op == token.Plus {
+		c.emitAdd(d, s, t)
+	} else if op == token.Minus {
+		c.emitSub(d, s, t)
+	} else if op == token.Times {
+		c.emitMul(s, t)
+		c.emitMoveFromLo(d)
+	} else if op == token.Divide {
+		c.emitDiv(s, t)
+		c.emitMoveFromLo(d)
+	} else {
+		// This can't possibly happen...
+		fmt.Println("A terrible error occurred.",
+			"The abstract syntax tree is wrong and I'm generating code...")
+	}
+}
+
+// generateCheckedOperation emits the instructions for a single arithmetic operation which report
+// an overflow at the line of the token and exit. $d = $s op $t; It clobbers $t3 to $t5.
+func (c *CodeGenerator) generateCheckedOperation(op int, tok *token.Token, d string, s string,
+	t string) {
+	label := c.getNewLabel("overflow")
+	if op == token.Plus {
+		c.emitAddUnsignedRegister("$t3", s, t)
+		// Adding numbers with different signs can't overflow.
+		c.emitXor("$t4", s, t)
+		c.emitBranchOnLessThanZero("$t4", label)
+		// Otherwise it overflowed if the sign of the result is different.
+		c.emitXor("$t4", "$t3", s)
+		c.emitBranchOnGreaterThanOrEqualZero("$t4", label)
+	} else if op == token.Minus {
+		c.emitSubUnsignedRegister("$t3", s, t)
+		// Subtracting numbers with the same sign can't overflow.
+		c.emitXor("$t4", s, t)
+		c.emitBranchOnGreaterThanOrEqualZero("$t4", label)
+		// Otherwise it overflowed if the sign of the result is different.
+		c.emitXor("$t4", "$t3", s)
+		c.emitBranchOnGreaterThanOrEqualZero("$t4", label)
+	} else if op == token.Times {
+		c.emitMul(s, t)
+		c.emitMoveFromLo("$t3")
+		// The product fits if the high word is just the sign extension of the low word.
+		c.emitMoveFromHi("$t4")
+		c.emitShiftRightArithmetic("$t5", "$t3", 31)
+		c.emitBranchOnEqual("$t4", "$t5", label)
+	} else if op == token.Divide {
+		c.emitDiv(s, t)
+		c.emitMoveFromLo("$t3")
+		// The only quotient that doesn't fit is the most negative number divided by -1.
+		c.emitLoadInt("$t4", -1)
+		c.emitBranchNotEqual(t, "$t4", label)
+		c.emitLoadInt("$t4", math.MinInt32)
+		c.emitBranchNotEqual(s, "$t4", label)
+	} else {
+		// This can't possibly happen...
+		fmt.Println("A terrible error occurred.",
+			"The abstract syntax tree is wrong and I'm generating code...")
+	}
+	c.emitLoadInt("$a2", 1)
+	c.generateFail("integer overflow", tok)
+	c.emitLabel(label)
+	c.emitMove(d, "$t3")
+}
+
+// generateDivisionCheck emits a check that the divisor in register t isn't 0. If it is, the program
+// reports the division by zero at the line of the token and exits.
+func (c *CodeGenerator) generateDivisionCheck(t string, tok *token.Token) {
+	label := c.getNewLabel("divide")
+	c.emitBranchNotEqual(t, "$zero", label)
+	c.emitLoadInt("$a2", 1)
+	c.generateFail("division by zero", tok)
+	c.emitLabel(label)
+}
+
+// generateRangeCheck emits a check that the value in register t is at least 0 and less than n. If
+// it isn't, the program reports the value out of range at the line of the token and exits. It
+// clobbers $t2.
+func (c *CodeGenerator) generateRangeCheck(t string, n int, tok *token.Token) {
+	label := c.getNewLabel("range")
+	c.emitLoadInt("$t2", n)
+	// Negative values are bigger than n when compared unsigned.
+	c.emitSetOnLessThanUnsigned("$t2", t, "$t2")
+	c.emitBranchNotEqual("$t2", "$zero", label)
+	c.emitLoadInt("$a2", 1)
+	c.generateFail("value out of range", tok)
+	c.emitLabel(label)
+}
+
+// isConstant returns a bool representing whether or not an expression node is an integer or a
+// constant, which the analyser has already checked isn't 0 when used as a divisor.
+func (c *CodeGenerator) isConstant(node *ast.Node, syms []*symtable.SymbolTable) bool {
+	if node.Tag != ast.Terminal {
+		return false
+	}
+	if node.Tok.Tag == token.Integer {
+		return true
+	}
+	// Variables shadow constants, so it's only a constant if it isn't a variable.
+	_, value := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Integer, node.Tok.Lex}, syms)
+	return value == nil
+}
+
+// generateTry emits a try statement. A handler record is pushed onto the stack and linked into the
+// handler chain before the statements run and is popped again if they finish. If an exception is
+// raised the raise routine pops the record and jumps to the handler, which compares the code in $a0
+// against the code of each handler. A code with no handler is raised again to the next record.
+func (c *CodeGenerator) generateTry(node *ast.Node, syms []*symtable.SymbolTable) {
+	label := c.getNewLabel("try")
+	handleLabel := label + "_handler"
+	doneLabel := label + "_done"
+	raiseLabel := c.useRuntime(runtimeRaise)
+	c.handlers = true
+	// Push the record: the previous record, the frame pointer and the handler address.
+	c.emitLoadAddress("$t0", handlerLabel)
+	c.emitLoadWord("$t1", "$t0", 0)
+	c.emitStoreWord("$t1", "$sp", 0)
+	c.emitStoreWord("$fp", "$sp", -4)
+	c.emitLoadAddress("$t1", handleLabel)
+	c.emitStoreWord("$t1", "$sp", -8)
+	c.emitStoreWord("$sp", "$t0", 0)
+	c.emitSubUnsigned("$sp", "$sp", 12)
+	c.generateStatement(node.Children[0], syms)
+	// Nothing was raised, so pop the record and unlink it.
+	c.emitAddUnsigned("$sp", "$sp", 12)
+	c.emitLoadWord("$t1", "$sp", 0)
+	c.emitLoadAddress("$t0", handlerLabel)
+	c.emitStoreWord("$t1", "$t0", 0)
+	c.emitJump(doneLabel)
+	c.emitLabel(handleLabel)
+	// The raise routine already unlinked the record but left it on the stack.
+	c.emitAddUnsigned("$sp", "$sp", 12)
+	for i, handler := range node.Children[1:] {
+		c.emitLoadInt("$t0", handler.Children[0].Tok.Val)
+		c.emitBranchOnEqual("$a0", "$t0", fmt.Sprintf("%s_%d", handleLabel, i))
+	}
+	c.emitJump(raiseLabel)
+	for i, handler := range node.Children[1:] {
+		c.emitLabel(fmt.Sprintf("%s_%d", handleLabel, i))
+		c.generateStatement(handler.Children[1], syms)
+		c.emitJump(doneLabel)
+	}
+	c.emitLabel(doneLabel)
+}
+
+// asmAddressRegister is the register an asm statement gets the address of a variable in.
+const asmAddressRegister = "$t9"
+
+// registerPattern matches the registers named in a line of assembly.
+var registerPattern = regexp.MustCompile(`\$[0-9a-z]+`)
+
+// generateAsm emits the lines of an asm statement. A line that refers to a variable gets its
+// address in asmAddressRegister with the same walk as any other variable, and the reference is
+// replaced with 0($t9). The block is preceded by a comment declaring every register it clobbers,
+// which is every register it names besides $zero.
+func (c *CodeGenerator) generateAsm(node *ast.Node, syms []*symtable.SymbolTable) {
+	lines := make([]string, len(node.Children))
+	clobbers := make([]string, 0)
+	seen := map[string]bool{"$zero": true}
+	for i, line := range node.Children {
+		lines[i] = line.Tok.Lex
+		if len(line.Children) > 0 {
+			open := strings.IndexByte(lines[i], '{')
+			close := strings.IndexByte(lines[i], '}')
+			lines[i] = lines[i][:open] + "0(" + asmAddressRegister + ")" + lines[i][close+1:]
+		}
+		for _, reg := range registerPattern.FindAllString(lines[i], -1) {
+			if !seen[reg] {
+				seen[reg] = true
+				clobbers = append(clobbers, reg)
+			}
+		}
+	}
+	if len(clobbers) == 0 {
+		clobbers = append(clobbers, "no registers")
+	}
+	c.writeOut(fmt.Sprintf("# ASM at line %d clobbers %s\n", node.Tok.Ln+1,
+		strings.Join(clobbers, ", ")))
+	for i, line := range node.Children {
+		if len(line.Children) > 0 {
+			key := symtable.Key{symtable.Integer, line.Children[0].Tok.Lex}
+			n, value := c.getValueFromClosestSymbolTable(key, syms)
+			c.loadAddressOfVariable(asmAddressRegister, n, value)
+		}
+		c.writeOut(lines[i] + "\n")
+	}
+}
+
+// generateFail emits a jump to the runtime routine that reports a failure at the line of the token
+// and exits. The exit code must already be in $a2.
+func (c *CodeGenerator) generateFail(msg string, tok *token.Token) {
+	c.emitLoadAddress("$a0", c.addString(msg))
+	c.emitLoadInt("$a1", tok.Ln+1)
+	c.emitJump(c.useRuntime(runtimeFail))
+}
+
+// generateAddress places the address of a variable or of an element of an array on the stack. The
+// index of an element is checked against the length of the array unless the code is unsafe.
+func (c *CodeGenerator) generateAddress(node *ast.Node, syms []*symtable.SymbolTable) {
+	if node.Tag == ast.Terminal {
+		key := symtable.Key{symtable.Integer, node.Tok.Lex}
+		n, value := c.getValueFromClosestSymbolTable(key, syms)
+		c.loadAddressOfVariable("$a0", n, value)
+		c.emitStoreWord("$a0", "$sp", 0)
+		c.emitSubUnsigned("$sp", "$sp", 4)
+		return
+	}
+	array := node.Children[0]
+	c.generateAddress(array, syms)
+	c.generateExpression(node.Children[1], syms)
+	// Pop the index and the address of the array off of the stack.
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t0", "$sp", 0)
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t1", "$sp", 0)
+	if !c.opt.Unsafe {
+		c.generateRangeCheck("$t0", array.Type.Len, node.Tok)
+	}
+	// The elements go up from the address of the array.
+	c.emitLoadInt("$t2", 4*node.Type.Size())
+	c.emitMul("$t0", "$t2")
+	c.emitMoveFromLo("$t0")
+	c.emitAddUnsignedRegister("$t0", "$t1", "$t0")
+	c.emitStoreWord("$t0", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+}
+
+// generateArrayCopy emits the assignment of a whole array, which is copied a word at a time.
+func (c *CodeGenerator) generateArrayCopy(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	label := c.getNewLabel("copy")
+	c.generateAddress(node.Children[1], syms)
+	c.loadAddressOfDesignator("$t0", iden, syms)
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t1", "$sp", 0)
+	c.emitLoadInt("$t2", iden.Type.Size())
+	c.emitLabel(label)
+	c.emitLoadWord("$t3", "$t1", 0)
+	c.emitStoreWord("$t3", "$t0", 0)
+	c.emitAddUnsigned("$t0", "$t0", 4)
+	c.emitAddUnsigned("$t1", "$t1", 4)
+	c.emitSubUnsigned("$t2", "$t2", 1)
+	c.emitBranchOnGreaterThanZero("$t2", label)
+}
+
+// loadAddressOfDesignator loads the address of the variable named by a terminal node, or of the
+// element of an array given by an index node, into register dest.
+func (c *CodeGenerator) loadAddressOfDesignator(dest string, node *ast.Node,
+	syms []*symtable.SymbolTable) {
+	if node.Tag == ast.Terminal {
+		key := symtable.Key{symtable.Integer, node.Tok.Lex}
+		n, value := c.getValueFromClosestSymbolTable(key, syms)
+		c.loadAddressOfVariable(dest, n, value)
+		return
+	}
+	c.generateAddress(node, syms)
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord(dest, "$sp", 0)
+}
+
+// frameWords returns the number of words taken up by the variables of a block in its activation
+// record.
+func (c *CodeGenerator) frameWords(bloc *ast.Node) int {
+	words := 0
+	for _, iden := range bloc.Children[2].Children {
+		words += bloc.Sym.Get(symtable.Key{symtable.Integer, iden.Tok.Lex}).Type.Size()
+	}
+	return words
+}
+
+// loadAddressOfVariable loads the address of a variable into register dest. Variables with a label
+// are in the data segment. Otherwise they are n activation records back.
+func (c *CodeGenerator) loadAddressOfVariable(dest string, n int, value *symtable.Value) {
+	if value.Label != "" {
+		c.emitLoadAddress(dest, value.Label)
+		return
+	}
+	c.loadAddressOfPreviousRecord(dest, n, value.Order)
+}
+
 // loadAddressOfPreviousRecord loads the address of the variable n activation records back  at
 // position m into register dest.
 func (c *CodeGenerator) loadAddressOfPreviousRecord(dest string, n int, m int) {
@@ -328,6 +1355,25 @@ func (c *CodeGenerator) loadAddressOfPreviousRecord(dest string, n int, m int) {
 	c.emitSubUnsigned(dest, dest, 4*m)
 }
 
+// addString places a null terminated string in the data segment and returns its label.
+func (c *CodeGenerator) addString(s string) string {
+	label := c.getNewLabel("string")
+	c.addLabelledString(label, s)
+	return label
+}
+
+// addWords places n zeroed and aligned words in the data segment under the given label.
+func (c *CodeGenerator) addWords(label string, n int) {
+	c.data.WriteString(fmt.Sprintf(".align 2\n%s: .space %d\n", label, 4*n))
+}
+
+// addLabelledString places a null terminated string in the data segment under the given label.
+func (c *CodeGenerator) addLabelledString(label string, s string) {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	c.data.WriteString(fmt.Sprintf("%s: .asciiz \"%s\"\n", label, s))
+}
+
 // emitAndImmediate emits a andi instruction. $t = $s & imm;
 func (c *CodeGenerator) emitAndImmediate(t string, s string, imm int) {
 	c.writeOut(fmt.Sprintf("andi %s %s %d\n", t, s, imm))
@@ -345,6 +1391,17 @@ func (c *CodeGenerator) emitBranchOnGreaterThanOrEqualZero(s string, l string) {
 	c.writeOut(fmt.Sprintf("bgez %s %s\n", s, l))
 }
 
+// emitBranchOnLessThanOrEqualZero emits a blez instruction. Jumps to l if s is less than or equal
+// to 0. if $s <= 0 j l;
+func (c *CodeGenerator) emitBranchOnLessThanOrEqualZero(s string, l string) {
+	c.writeOut(fmt.Sprintf("blez %s %s\n", s, l))
+}
+
+// emitBranchOnLessThanZero emits a bltz instruction. Jumps to l if s is less than 0. if $s < 0 j l;
+func (c *CodeGenerator) emitBranchOnLessThanZero(s string, l string) {
+	c.writeOut(fmt.Sprintf("bltz %s %s\n", s, l))
+}
+
 // emitBranchOnEqual emits a beq instruction. Jumps to l if s is equal to t. if $s == $t j l;
 func (c *CodeGenerator) emitBranchOnEqual(s string, t string, l string) {
 	c.writeOut(fmt.Sprintf("beq %s %s %s\n", s, t, l))
@@ -365,9 +1422,13 @@ func (c *CodeGenerator) emitJumpAndLink(l string) {
 	c.writeOut(fmt.Sprintf("jal %s\n", l))
 }
 
+// emitJumpRegister emits a jr instruction to the address in a register. jr $s;
+func (c *CodeGenerator) emitJumpRegister(s string) {
+	c.writeOut(fmt.Sprintf("jr %s\n", s))
+}
+
 // emitJumpReturn emits a jr instruction to $ra. jr $ra;
 func (c *CodeGenerator) emitJumpReturn() {
-	// I'm pretty sure we'll only jr to $ra.
 	c.writeOut("jr $ra\n")
 }
 
@@ -376,6 +1437,26 @@ func (c *CodeGenerator) emitLoadWord(t string, s string, offset int) {
 	c.writeOut(fmt.Sprintf("lw %s %d(%s)\n", t, offset, s))
 }
 
+// emitLoadByte emits a lb instruction. $t = MEM[$s + offset];
+func (c *CodeGenerator) emitLoadByte(t string, s string, offset int) {
+	c.writeOut(fmt.Sprintf("lb %s %d(%s)\n", t, offset, s))
+}
+
+// emitLoadByteUnsigned emits a lbu instruction. $t = MEM[$s + offset] & 0xff;
+func (c *CodeGenerator) emitLoadByteUnsigned(t string, s string, offset int) {
+	c.writeOut(fmt.Sprintf("lbu %s %d(%s)\n", t, offset, s))
+}
+
+// emitStoreByte emits a sb instruction. MEM[$s + offset] = $t & 0xff;
+func (c *CodeGenerator) emitStoreByte(t string, s string, offset int) {
+	c.writeOut(fmt.Sprintf("sb %s %d(%s)\n", t, offset, s))
+}
+
+// emitLoadAddress emits a la instruction. $t = &l;
+func (c *CodeGenerator) emitLoadAddress(t string, l string) {
+	c.writeOut(fmt.Sprintf("la %s %s\n", t, l))
+}
+
 // emitLoadInt emits a li instruction. $t = imm
 func (c *CodeGenerator) emitLoadInt(t string, imm int) {
 	c.writeOut(fmt.Sprintf("li %s %d\n", t, imm))
@@ -396,6 +1477,11 @@ func (c *CodeGenerator) emitSubUnsigned(d string, s string, imm int) {
 	c.writeOut(fmt.Sprintf("subu %s %s %d\n", d, s, imm))
 }
 
+// emitAddUnsignedRegister emits a addu instruction with a register operand. $d = $s + $t;
+func (c *CodeGenerator) emitAddUnsignedRegister(d string, s string, t string) {
+	c.writeOut(fmt.Sprintf("addu %s %s %s\n", d, s, t))
+}
+
 // emitAdd emits an add instruction. $d = $s + $t;
 func (c *CodeGenerator) emitAdd(d string, s string, t string) {
 	c.writeOut(fmt.Sprintf("add %s %s %s\n", d, s, t))
@@ -406,6 +1492,56 @@ func (c *CodeGenerator) emitSub(d string, s string, t string) {
 	c.writeOut(fmt.Sprintf("sub %s %s %s\n", d, s, t))
 }
 
+// emitSubUnsignedRegister emits a subu instruction with a register operand. $d = $s - $t;
+func (c *CodeGenerator) emitSubUnsignedRegister(d string, s string, t string) {
+	c.writeOut(fmt.Sprintf("subu %s %s %s\n", d, s, t))
+}
+
+// emitAnd emits an and instruction. $d = $s & $t;
+func (c *CodeGenerator) emitAnd(d string, s string, t string) {
+	c.writeOut(fmt.Sprintf("and %s %s %s\n", d, s, t))
+}
+
+// emitOr emits an or instruction. $d = $s | $t;
+func (c *CodeGenerator) emitOr(d string, s string, t string) {
+	c.writeOut(fmt.Sprintf("or %s %s %s\n", d, s, t))
+}
+
+// emitNor emits a nor instruction. $d = ~($s | $t);
+func (c *CodeGenerator) emitNor(d string, s string, t string) {
+	c.writeOut(fmt.Sprintf("nor %s %s %s\n", d, s, t))
+}
+
+// emitXor emits a xor instruction. $d = $s ^ $t;
+func (c *CodeGenerator) emitXor(d string, s string, t string) {
+	c.writeOut(fmt.Sprintf("xor %s %s %s\n", d, s, t))
+}
+
+// emitShiftRightArithmetic emits a sra instruction. $d = $t >> shamt;
+func (c *CodeGenerator) emitShiftRightArithmetic(d string, t string, shamt int) {
+	c.writeOut(fmt.Sprintf("sra %s %s %d\n", d, t, shamt))
+}
+
+// emitShiftLeftLogicalVariable emits a sllv instruction. $d = $t << $s;
+func (c *CodeGenerator) emitShiftLeftLogicalVariable(d string, t string, s string) {
+	c.writeOut(fmt.Sprintf("sllv %s %s %s\n", d, t, s))
+}
+
+// emitShiftRightLogicalVariable emits a srlv instruction. $d = $t >> $s;
+func (c *CodeGenerator) emitShiftRightLogicalVariable(d string, t string, s string) {
+	c.writeOut(fmt.Sprintf("srlv %s %s %s\n", d, t, s))
+}
+
+// emitSetOnLessThan emits a slt instruction. $d = $s < $t;
+func (c *CodeGenerator) emitSetOnLessThan(d string, s string, t string) {
+	c.writeOut(fmt.Sprintf("slt %s %s %s\n", d, s, t))
+}
+
+// emitSetOnLessThanUnsigned emits a sltu instruction. $d = $s < $t (unsigned);
+func (c *CodeGenerator) emitSetOnLessThanUnsigned(d string, s string, t string) {
+	c.writeOut(fmt.Sprintf("sltu %s %s %s\n", d, s, t))
+}
+
 // emitMult emits a mult instruction. $LO = $s * $t;
 func (c *CodeGenerator) emitMul(s string, t string) {
 	c.writeOut(fmt.Sprintf("mult %s %s\n", s, t))
@@ -421,14 +1557,52 @@ func (c *CodeGenerator) emitMoveFromLo(d string) {
 	c.writeOut(fmt.Sprintf("mflo %s\n", d))
 }
 
+// emitMoveToCoprocessor emits a mtc1 instruction. $f = $t;
+func (c *CodeGenerator) emitMoveToCoprocessor(t string, f string) {
+	c.writeOut(fmt.Sprintf("mtc1 %s %s\n", t, f))
+}
+
+// emitMoveFromCoprocessor emits a mfc1 instruction. $t = $f;
+func (c *CodeGenerator) emitMoveFromCoprocessor(t string, f string) {
+	c.writeOut(fmt.Sprintf("mfc1 %s %s\n", t, f))
+}
+
+// emitFloatInstruction emits a floating point coprocessor instruction on the given registers, ex.
+// add.s $f0 $f0 $f2.
+func (c *CodeGenerator) emitFloatInstruction(op string, f ...string) {
+	c.writeOut(fmt.Sprintf("%s %s\n", op, strings.Join(f, " ")))
+}
+
+// emitFloatCompare emits a c.cond.s instruction which sets the coprocessor condition flag. The
+// condition is one of eq, lt or le. flag = $f cond $g;
+func (c *CodeGenerator) emitFloatCompare(cond string, f string, g string) {
+	c.writeOut(fmt.Sprintf("c.%s.s %s %s\n", cond, f, g))
+}
+
+// emitBranchOnFloatTrue emits a bc1t instruction. Jumps to l if the condition flag is set.
+func (c *CodeGenerator) emitBranchOnFloatTrue(l string) {
+	c.writeOut(fmt.Sprintf("bc1t %s\n", l))
+}
+
+// emitBranchOnFloatFalse emits a bc1f instruction. Jumps to l if the condition flag isn't set.
+func (c *CodeGenerator) emitBranchOnFloatFalse(l string) {
+	c.writeOut(fmt.Sprintf("bc1f %s\n", l))
+}
+
+// emitMoveFromHi emits a mfhi instruction. $d = $HI;
+func (c *CodeGenerator) emitMoveFromHi(d string) {
+	c.writeOut(fmt.Sprintf("mfhi %s\n", d))
+}
+
 // emitMove emits a move instruction. $t = $s;
 func (c *CodeGenerator) emitMove(t string, s string) {
 	c.writeOut(fmt.Sprintf("move %s %s\n", t, s))
 }
 
-// getNewLabel returns the specified base label appended with a unique integer.
+// getNewLabel returns the specified base label appended with a unique integer. It has the module
+// prefix if a module is being generated.
 func (c *CodeGenerator) getNewLabel(base string) string {
-	label := fmt.Sprintf("%s%d", base, c.count)
+	label := fmt.Sprintf("%s%s%d", c.prefix, base, c.count)
 	c.count++
 	return label
 }
@@ -438,6 +1612,11 @@ func (c *CodeGenerator) emitLabel(label string) {
 	c.writeOut(label + ":\n")
 }
 
+// emitGlobal emits a .globl directive which makes a label visible to other files.
+func (c *CodeGenerator) emitGlobal(label string) {
+	c.writeOut(fmt.Sprintf(".globl %s\n", label))
+}
+
 // emitSyscall emits a spim syscall.
 func (c *CodeGenerator) emitSyscall() {
 	c.writeOut("syscall\n")
@@ -456,6 +1635,22 @@ func (c *CodeGenerator) getValueFromClosestSymbolTable(key symtable.Key,
 	return 0, nil
 }
 
+// hasTag returns a bool representing whether or not a node or any node under it has the given tag.
+func hasTag(node *ast.Node, tag int) bool {
+	if node == nil {
+		return false
+	}
+	if node.Tag == tag {
+		return true
+	}
+	for _, child := range node.Children {
+		if hasTag(child, tag) {
+			return true
+		}
+	}
+	return false
+}
+
 // writeOut takes a string and prints it to the buffer if not nil. Otherwise it prints to stdout.
 func (c *CodeGenerator) writeOut(s string) {
 	if c.buf != nil {
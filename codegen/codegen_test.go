@@ -0,0 +1,85 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/saicheems/simplelang/analyser"
+	"github.com/saicheems/simplelang/lexer"
+	"github.com/saicheems/simplelang/parser"
+)
+
+type testPair struct {
+	test   string
+	expect string
+}
+
+var tests = []testPair{
+	{"VAR x;\nBEGIN\n\tx := 1;\n\tASSERT(x = 2);\nEND.", "li $a1 4\nj runtime_fail\n"},
+	{"ASSERT(1 = 2, 0).", "li $a2 1\nassert0:\n"},
+	{"VAR x, y;\nBEGIN\n\tx := 1;\n\ty := x / y;\nEND.", "li $a1 4\nj runtime_fail\n"},
+	{"VAR i; CASE i OF 2 * 3: i := 1; END.", "li $t1 6\nbeq $t0 $t1 case0_arm0\n"},
+	{"VAR x;\nBEGIN\n\tx := 1;\n\tRAISE x;\nEND.", "li $a1 4\nj runtime_raise\n"},
+	{"VAR x;\nCOROUTINE g;\n\tYIELD 1;\nBEGIN\n\tx := RESUME g;\n\tx := RESUME g;\nEND.",
+		"li $a1 6\njal coroutine0\n"},
+	{"COROUTINE g; PROCEDURE p; YIELD 1; CALL p; ! RESUME g.", "stack overflow in procedure p"},
+	{"PROCEDURE p; CALL p; COBEGIN CALL p COEND.", "stack overflow in procedure p"},
+	{"COROUTINE g; PROCEDURE p; VAR v: ARRAY 1000 OF INTEGER; YIELD 1; CALL p; ! RESUME g.",
+		"addu $t3 $t3 4268\n"},
+	{"VAR f: FILE;\nOPEN(f, \"a\", 1).", "li $a1 577\nj open0\n"},
+	{"VAR f: FILE;\nOPEN(f, \"a\", 9).", "li $a1 1089\nj open0\n"},
+	{"VAR f: FILE;\nOPEN(f, \"a\").", "li $a1 0\nla $a0 string0\nli $a2 420\n"},
+	{"VAR f: FILE, x;\nBEGIN\n\tOPEN(f, \"a\");\n\tREAD(f, x);\nEND.",
+		"li $a1 4\njal runtime_read_int\n"},
+	{"VAR f: FILE;\nBEGIN\n\tOPEN(f, \"a\", 1);\n\tWRITE(f, 1);\nEND.",
+		"li $a3 4\njal runtime_file_int\n"},
+	{"EXTERNAL PROCEDURE e(a); COROUTINE g; YIELD e(1); ! RESUME g.", "li $v0 10\nsyscall\n"},
+	{"VAR s: STRING, c: CHAR, i;\nBEGIN\n\ti := 5;\n\tc := s[i];\nEND.", "li $a1 4\nj runtime_fail\n"},
+	{"VAR x;\nBEGIN\n\tASM\n\t\t\"lw $t0, {x}\"\n\tEND;\nEND.", "# ASM at line 3 clobbers $t0, $t9\n"},
+}
+
+// Programs whose names could collide with the labels generated for them.
+var labelTests = []string{
+	"MODULE m; VAR procedure0, if1; PROCEDURE p; procedure0 := if1; PROCEDURE q; CALL p;.",
+	"MODULE main; VAR main, m; PROCEDURE p; m := main;.",
+}
+
+func TestGenerate(t *testing.T) {
+	for _, pair := range tests {
+		c := New(analyser.New(parser.New(lexer.NewFromString(pair.test))))
+		c.Generate()
+		got := c.String()
+
+		if !strings.Contains(got, pair.expect) {
+			t.Error(
+				"\nFor\n------\n"+pair.test,
+				"\n------\nExpected\n------\n", pair.expect,
+				"\n------\nGot\n------\n", got,
+			)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	for _, test := range labelTests {
+		c := New(analyser.New(parser.New(lexer.NewFromString(test))))
+		c.Generate()
+		got := c.String()
+
+		seen := make(map[string]bool)
+		for _, line := range strings.Split(got, "\n") {
+			i := strings.Index(line, ":")
+			if i <= 0 || strings.ContainsAny(line[:i], " \t") {
+				continue
+			}
+			if seen[line[:i]] {
+				t.Error(
+					"\nFor\n------\n"+test,
+					"\n------\nDuplicate label\n------\n", line[:i],
+					"\n------\nGot\n------\n", got,
+				)
+			}
+			seen[line[:i]] = true
+		}
+	}
+}
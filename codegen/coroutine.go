@@ -0,0 +1,184 @@
+package codegen
+
+import (
+	"github.com/saicheems/simplelang/ast"
+	"github.com/saicheems/simplelang/symtable"
+)
+
+// coroutineStackSize is the size in bytes of the stack each coroutine runs on.
+const coroutineStackSize = 4096
+
+// stackSlack is the room in bytes a guard leaves for the values expressions push between two checks
+// of the stack.
+const stackSlack = 256
+
+// Positions of the words in the state of a coroutine. The state is kept in the data segment.
+const (
+	stateStatus         = iota // One of the coroutine statuses below.
+	stateSP                    // Stack pointer of the coroutine while it's suspended.
+	stateFP                    // Frame pointer of the coroutine while it's suspended.
+	stateResume                // Address the coroutine continues at when it's resumed.
+	stateResumerSP             // Stack pointer of whoever resumed the coroutine.
+	stateResumerFP             // Frame pointer of whoever resumed the coroutine.
+	stateResumerRA             // Return address of the RESUME.
+	stateLine                  // Line of the RESUME, for reporting a finished coroutine.
+	stateHandler               // Exception handler chain of the coroutine while it's suspended.
+	stateResumerHandler        // Exception handler chain of whoever resumed the coroutine.
+	stateLimit                 // Stack limit of whoever resumed the coroutine.
+	stateWords                 // Number of words in the state.
+)
+
+// Statuses of a coroutine.
+const (
+	coroutineNew = iota
+	coroutineSuspended
+	coroutineRunning
+	coroutineFinished
+)
+
+// generateCoroutine emits a coroutine. Its label is the code RESUME jumps and links to with the
+// static link in $a0 and the line of the RESUME in $a1. It saves the context of the resumer in the
+// state of the coroutine and switches to the stack of the coroutine: the first time it builds an
+// activation record there like a CALL would, afterwards it continues after the last YIELD. YIELD
+// switches back to the resumer with the yielded value in $v0. A coroutine that finishes, or one
+// that is resumed while it's running, is a runtime failure.
+func (c *CodeGenerator) generateCoroutine(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	bloc := node.Children[1]
+	numVars := c.frameWords(bloc)
+	label := c.getNewLabel("coroutine")
+	state := label + "_state"
+	stack := label + "_stack"
+	startLabel := label + "_start"
+	bodyLabel := label + "_body"
+	runningLabel := label + "_running"
+	finishedLabel := label + "_finished"
+	c.addWords(stack, (c.guard+coroutineStackSize)/4)
+	c.addWords(state, stateWords)
+	key := symtable.Key{symtable.Coroutine, iden.Tok.Lex}
+	syms[len(syms)-1].Put(key, &symtable.Value{Label: label, NumVars: numVars})
+	// Every coroutine has its own exception handler chain.
+	c.handlers = true
+
+	c.emitLabel(label)
+	c.emitLoadAddress("$t0", state)
+	c.emitLoadWord("$t1", "$t0", 4*stateStatus)
+	c.emitLoadInt("$t2", coroutineRunning)
+	c.emitBranchOnEqual("$t1", "$t2", runningLabel)
+	c.emitLoadInt("$t2", coroutineFinished)
+	c.emitBranchOnEqual("$t1", "$t2", finishedLabel)
+	// Save the context of the resumer and swap in the handler chain and stack limit.
+	c.emitStoreWord("$sp", "$t0", 4*stateResumerSP)
+	c.emitStoreWord("$fp", "$t0", 4*stateResumerFP)
+	c.emitStoreWord("$ra", "$t0", 4*stateResumerRA)
+	c.emitStoreWord("$a1", "$t0", 4*stateLine)
+	c.emitLoadAddress("$t2", handlerLabel)
+	c.emitLoadWord("$t3", "$t2", 0)
+	c.emitStoreWord("$t3", "$t0", 4*stateResumerHandler)
+	c.emitLoadWord("$t3", "$t0", 4*stateHandler)
+	c.emitStoreWord("$t3", "$t2", 0)
+	if c.checkStack {
+		c.emitLoadAddress("$t2", stackLimitLabel)
+		c.emitLoadWord("$t3", "$t2", 0)
+		c.emitStoreWord("$t3", "$t0", 4*stateLimit)
+		c.emitLoadAddress("$t3", stack)
+		c.emitAddUnsigned("$t3", "$t3", c.guard)
+		c.emitStoreWord("$t3", "$t2", 0)
+	}
+	c.emitLoadInt("$t2", coroutineRunning)
+	c.emitStoreWord("$t2", "$t0", 4*stateStatus)
+	c.emitBranchOnEqual("$t1", "$zero", startLabel)
+	// Continue where the coroutine left off.
+	c.emitLoadWord("$sp", "$t0", 4*stateSP)
+	c.emitLoadWord("$fp", "$t0", 4*stateFP)
+	c.emitLoadWord("$t1", "$t0", 4*stateResume)
+	c.emitJumpRegister("$t1")
+	c.emitLabel(runningLabel)
+	c.emitLoadAddress("$a0", c.addString("coroutine "+iden.Tok.Lex+" is already running"))
+	c.emitLoadInt("$a2", 1)
+	c.emitJump(c.useRuntime(runtimeFail))
+	c.emitLabel(finishedLabel)
+	c.emitLoadAddress("$a0", c.addString("coroutine "+iden.Tok.Lex+" has finished"))
+	c.emitLoadInt("$a2", 1)
+	c.emitJump(c.useRuntime(runtimeFail))
+	// Start the coroutine with an activation record at the top of its own stack.
+	c.emitLabel(startLabel)
+	c.emitLoadAddress("$sp", stack)
+	c.emitAddUnsigned("$sp", "$sp", c.guard+coroutineStackSize-4)
+	c.emitStoreWord("$fp", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+	c.emitStoreWord("$a0", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+	c.emitMove("$fp", "$sp")
+	for i := 0; i < numVars; i++ {
+		c.emitLoadInt("$a0", 0)
+		c.emitStoreWord("$a0", "$sp", 0)
+		c.emitSubUnsigned("$sp", "$sp", 4)
+	}
+	c.emitJump(bodyLabel)
+
+	outer := c.coroutine
+	c.coroutine = state
+	c.generateStatics(bloc, label)
+	nestSyms := append(syms, bloc.Sym)
+	c.generateProcedure(bloc.Children[4], nestSyms)
+	c.emitLabel(bodyLabel)
+	c.generateStatement(bloc.Children[5], nestSyms)
+	c.coroutine = outer
+	// The coroutine finished, so there's nothing to give the resumer.
+	c.emitLoadAddress("$t0", state)
+	c.emitLoadInt("$t1", coroutineFinished)
+	c.emitStoreWord("$t1", "$t0", 4*stateStatus)
+	c.emitLoadWord("$a1", "$t0", 4*stateLine)
+	c.emitJump(finishedLabel)
+}
+
+// generateSwitchToResumer emits the end of a YIELD. It saves the handler chain of the coroutine
+// whose state is in $t0, restores the context of its resumer and returns to the RESUME with $v0
+// intact.
+func (c *CodeGenerator) generateSwitchToResumer() {
+	c.emitLoadAddress("$t2", handlerLabel)
+	c.emitLoadWord("$t3", "$t2", 0)
+	c.emitStoreWord("$t3", "$t0", 4*stateHandler)
+	c.emitLoadWord("$t3", "$t0", 4*stateResumerHandler)
+	c.emitStoreWord("$t3", "$t2", 0)
+	if c.checkStack {
+		c.emitLoadWord("$t3", "$t0", 4*stateLimit)
+		c.emitLoadAddress("$t2", stackLimitLabel)
+		c.emitStoreWord("$t3", "$t2", 0)
+	}
+	c.emitLoadWord("$sp", "$t0", 4*stateResumerSP)
+	c.emitLoadWord("$fp", "$t0", 4*stateResumerFP)
+	c.emitLoadWord("$ra", "$t0", 4*stateResumerRA)
+	c.emitJumpReturn()
+}
+
+// setStackCheck decides whether procedures check the stack against the limit on entry. They do if
+// there's a stack size and, unless the code is unsafe, if the block has coroutines or a cobegin,
+// since those run on small stacks of their own. A call writes the activation record before the
+// procedure checks the stack, so each of those stacks has a guard below its limit with room for the
+// biggest record.
+func (c *CodeGenerator) setStackCheck(bloc *ast.Node) {
+	ownStacks := hasTag(bloc.Children[5], ast.Cobegin)
+	for _, node := range bloc.Children[4].Children {
+		ownStacks = ownStacks || node.Tag == ast.Coroutine
+	}
+	c.checkStack = c.opt.StackSize > 0 || !c.opt.Unsafe && ownStacks
+	c.guard = 4*(c.maxFrameWords(bloc)+3) + stackSlack
+}
+
+// maxFrameWords returns the number of words taken up by the variables of the biggest activation
+// record of a block and the procedures and coroutines nested in it. External procedures have no
+// activation record.
+func (c *CodeGenerator) maxFrameWords(bloc *ast.Node) int {
+	words := c.frameWords(bloc)
+	for _, node := range bloc.Children[4].Children {
+		if node.Tag == ast.External {
+			continue
+		}
+		if n := c.maxFrameWords(node.Children[1]); n > words {
+			words = n
+		}
+	}
+	return words
+}
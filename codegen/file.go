@@ -0,0 +1,312 @@
+package codegen
+
+import (
+	"fmt"
+
+	"github.com/saicheems/simplelang/ast"
+	"github.com/saicheems/simplelang/symtable"
+	"github.com/saicheems/simplelang/token"
+)
+
+// openModes maps the modes OPEN takes to the flags of the open syscall. Writing is O_WRONLY |
+// O_CREAT | O_TRUNC and appending is O_WRONLY | O_CREAT | O_APPEND. Reading is 0 in both.
+var openModes = []struct{ mode, flags int }{{1, 577}, {9, 1089}}
+
+// openPermissions is the mode a file created by OPEN gets, rw-r--r--.
+const openPermissions = 0644
+
+// generateOpen emits an open statement. The name of the file is placed in the data segment and the
+// descriptor the open syscall returns is stored in the file variable. Without a mode the file is
+// opened for reading.
+func (c *CodeGenerator) generateOpen(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	name := node.Children[1]
+	if len(node.Children) > 2 {
+		c.generateExpression(node.Children[2], syms)
+		// Pop the mode off of the stack.
+		c.emitAddUnsigned("$sp", "$sp", 4)
+		c.emitLoadWord("$a1", "$sp", 0)
+		// The mode is only known at runtime, so translate it to the flags there.
+		label := c.getNewLabel("open")
+		for i, m := range openModes {
+			next := fmt.Sprintf("%s_mode%d", label, i)
+			c.emitLoadInt("$t1", m.mode)
+			c.emitBranchNotEqual("$a1", "$t1", next)
+			c.emitLoadInt("$a1", m.flags)
+			c.emitJump(label)
+			c.emitLabel(next)
+		}
+		c.emitLabel(label)
+	} else {
+		c.emitLoadInt("$a1", 0)
+	}
+	c.emitLoadAddress("$a0", c.addString(name.Tok.Lex))
+	c.emitLoadInt("$a2", openPermissions)
+	c.emitLoadInt("$v0", 13)
+	c.emitSyscall()
+	c.generateFileCheck("$v0", "OPEN", node.Tok)
+	n, value := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Integer, iden.Tok.Lex}, syms)
+	c.loadAddressOfVariable("$t0", n, value)
+	c.emitStoreWord("$v0", "$t0", 0)
+}
+
+// generateClose emits a close statement. The file variable is set to -1 afterwards so that using
+// the file again fails.
+func (c *CodeGenerator) generateClose(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	n, value := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Integer, iden.Tok.Lex}, syms)
+	c.loadAddressOfVariable("$t0", n, value)
+	c.emitLoadWord("$a0", "$t0", 0)
+	c.generateFileCheck("$a0", "CLOSE", node.Tok)
+	c.emitLoadInt("$v0", 16)
+	c.emitSyscall()
+	c.emitLoadInt("$t1", -1)
+	c.emitStoreWord("$t1", "$t0", 0)
+}
+
+// generateRead emits a read statement. Each variable gets the next integer in the file.
+func (c *CodeGenerator) generateRead(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	n, file := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Integer, iden.Tok.Lex}, syms)
+	for _, iden := range node.Children[1:] {
+		c.loadAddressOfVariable("$a0", n, file)
+		c.emitLoadWord("$a0", "$a0", 0)
+		c.emitLoadInt("$a1", node.Tok.Ln+1)
+		c.emitJumpAndLink(c.useRuntime(runtimeReadInt))
+		m, value := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Integer, iden.Tok.Lex},
+			syms)
+		c.loadAddressOfVariable("$t0", m, value)
+		c.emitStoreWord("$v0", "$t0", 0)
+	}
+}
+
+// generateFileWrite emits a write statement whose first child is the terminal Node naming the file
+// to write to. Every item goes through a runtime routine, with a width of 0 if it has none.
+func (c *CodeGenerator) generateFileWrite(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	n, file := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Integer, iden.Tok.Lex}, syms)
+	for _, node := range node.Children[1:] {
+		item := node.Children[0]
+		isString := item.Tag == ast.Terminal && item.Tok.Tag == token.String
+		if !isString {
+			c.generateExpression(item, syms)
+		}
+		if len(node.Children) > 1 {
+			c.generateExpression(node.Children[1], syms)
+			// Pop the width off of the stack.
+			c.emitAddUnsigned("$sp", "$sp", 4)
+			c.emitLoadWord("$a1", "$sp", 0)
+		} else {
+			c.emitLoadInt("$a1", 0)
+		}
+		routine := runtimeFileString
+		if isString {
+			c.emitLoadAddress("$a0", c.addString(item.Tok.Lex))
+		} else {
+			// Pop the value off of the stack.
+			c.emitAddUnsigned("$sp", "$sp", 4)
+			c.emitLoadWord("$a0", "$sp", 0)
+			routine = runtimeFileInt
+		}
+		if item.Type == symtable.StringType {
+			// Skip the length to get to the null terminated characters.
+			c.emitAddUnsigned("$a0", "$a0", 4)
+			routine = runtimeFileString
+		}
+		c.generateFileWriteCall(routine, n, file, iden.Tok)
+	}
+	if node.Op == token.Writeln {
+		c.emitLoadAddress("$a0", c.prefix+runtimeFileString+"_newline")
+		c.emitLoadInt("$a1", 0)
+		c.generateFileWriteCall(runtimeFileString, n, file, iden.Tok)
+	}
+}
+
+// generateFileWriteCall emits a call to one of the routines that write to a file with the value in
+// $a0 and the width in $a1. The file is n activation records back.
+func (c *CodeGenerator) generateFileWriteCall(routine string, n int, file *symtable.Value,
+	tok *token.Token) {
+	c.loadAddressOfVariable("$a2", n, file)
+	c.emitLoadWord("$a2", "$a2", 0)
+	c.emitLoadInt("$a3", tok.Ln+1)
+	c.emitJumpAndLink(c.useRuntime(routine))
+}
+
+// generateFileCheck emits a check of the descriptor or result of a file operation in register t. If
+// it's negative the operation failed, which is reported at the line of the token.
+func (c *CodeGenerator) generateFileCheck(t string, op string, tok *token.Token) {
+	label := c.getNewLabel("file")
+	c.emitBranchOnGreaterThanOrEqualZero(t, label)
+	c.emitLoadInt("$a2", 1)
+	c.generateFail(op+" failed", tok)
+	c.emitLabel(label)
+}
+
+// generateReadIntRoutine emits the routine that reads an integer from the file $a0 a character at a
+// time. It skips whitespace, takes an optional minus sign and then digits up to the first character
+// that isn't one. A bad descriptor, the end of the file or anything else before the digits is
+// reported at line $a1 and exits with 1.
+func (c *CodeGenerator) generateReadIntRoutine() {
+	label := c.prefix + runtimeReadInt
+	bufferLabel := label + "_buffer"
+	readLabel := label + "_read"
+	otherLabel := label + "_other"
+	spaceLabel := label + "_space"
+	eofLabel := label + "_eof"
+	doneLabel := label + "_done"
+	failedLabel := label + "_failed"
+	noNumberLabel := label + "_no_number"
+	pastEndLabel := label + "_past_end"
+	failLabel := label + "_fail"
+	c.addWords(bufferLabel, 1)
+	c.emitLabel(label)
+	c.emitMove("$t0", "$a0")
+	c.emitMove("$t1", "$a1")
+	c.emitBranchOnLessThanZero("$t0", failedLabel)
+	c.emitLoadInt("$t2", 0) // The digits read so far.
+	c.emitLoadInt("$t3", 1) // The sign.
+	c.emitLoadInt("$t6", 0) // 0 before the number, 1 after the sign and 2 in the digits.
+	c.emitLabel(readLabel)
+	c.emitMove("$a0", "$t0")
+	c.emitLoadAddress("$a1", bufferLabel)
+	c.emitLoadInt("$a2", 1)
+	c.emitLoadInt("$v0", 14)
+	c.emitSyscall()
+	c.emitBranchOnLessThanZero("$v0", failedLabel)
+	c.emitBranchOnEqual("$v0", "$zero", eofLabel)
+	c.emitLoadByte("$t4", "$a1", 0)
+	c.emitSubUnsigned("$t5", "$t4", 48)
+	c.emitBranchOnLessThanZero("$t5", otherLabel)
+	c.emitLoadInt("$t7", 9)
+	c.emitSetOnLessThan("$t7", "$t7", "$t5")
+	c.emitBranchNotEqual("$t7", "$zero", otherLabel)
+	c.emitLoadInt("$t7", 10)
+	c.emitMul("$t2", "$t7")
+	c.emitMoveFromLo("$t2")
+	c.emitAddUnsignedRegister("$t2", "$t2", "$t5")
+	c.emitLoadInt("$t6", 2)
+	c.emitJump(readLabel)
+	c.emitLabel(otherLabel)
+	// Anything after the digits ends the number.
+	c.emitLoadInt("$t7", 2)
+	c.emitBranchOnEqual("$t6", "$t7", doneLabel)
+	c.emitBranchNotEqual("$t6", "$zero", noNumberLabel)
+	c.emitLoadInt("$t7", 45) // Minus sign.
+	c.emitBranchNotEqual("$t4", "$t7", spaceLabel)
+	c.emitLoadInt("$t3", -1)
+	c.emitLoadInt("$t6", 1)
+	c.emitJump(readLabel)
+	c.emitLabel(spaceLabel)
+	// Spaces and control characters are skipped.
+	c.emitLoadInt("$t7", 32)
+	c.emitSetOnLessThan("$t7", "$t7", "$t4")
+	c.emitBranchNotEqual("$t7", "$zero", noNumberLabel)
+	c.emitJump(readLabel)
+	c.emitLabel(eofLabel)
+	c.emitLoadInt("$t7", 2)
+	c.emitBranchNotEqual("$t6", "$t7", pastEndLabel)
+	c.emitLabel(doneLabel)
+	c.emitMul("$t2", "$t3")
+	c.emitMoveFromLo("$v0")
+	c.emitJumpReturn()
+	c.emitLabel(failedLabel)
+	c.emitLoadAddress("$a0", c.addString("READ failed"))
+	c.emitJump(failLabel)
+	c.emitLabel(noNumberLabel)
+	c.emitLoadAddress("$a0", c.addString("READ found no number"))
+	c.emitJump(failLabel)
+	c.emitLabel(pastEndLabel)
+	c.emitLoadAddress("$a0", c.addString("READ past end of file"))
+	c.emitLabel(failLabel)
+	c.emitMove("$a1", "$t1")
+	c.emitLoadInt("$a2", 1)
+	c.emitJump(c.useRuntime(runtimeFail))
+}
+
+// generateFileIntRoutine emits the routine that writes the integer $a0 to a file. It turns the
+// integer into a null terminated string in a buffer, working on the negative of positive numbers
+// so that the smallest integer fits, and leaves the rest to the routine that writes strings.
+func (c *CodeGenerator) generateFileIntRoutine() {
+	label := c.prefix + runtimeFileInt
+	bufferLabel := label + "_buffer"
+	digitLabel := label + "_digit"
+	writeLabel := label + "_write"
+	c.addWords(bufferLabel, 3)
+	c.emitLabel(label)
+	c.emitLoadAddress("$t0", bufferLabel)
+	c.emitAddUnsigned("$t0", "$t0", 11)
+	c.emitStoreByte("$zero", "$t0", 0)
+	c.emitMove("$t2", "$a0")
+	c.emitBranchOnLessThanOrEqualZero("$t2", digitLabel)
+	c.emitSub("$t2", "$zero", "$t2")
+	c.emitLabel(digitLabel)
+	c.emitLoadInt("$t3", 10)
+	c.emitDiv("$t2", "$t3")
+	c.emitMoveFromLo("$t2")
+	c.emitMoveFromHi("$t4")
+	c.emitSub("$t4", "$zero", "$t4")
+	c.emitAddUnsigned("$t4", "$t4", 48)
+	c.emitSubUnsigned("$t0", "$t0", 1)
+	c.emitStoreByte("$t4", "$t0", 0)
+	c.emitBranchNotEqual("$t2", "$zero", digitLabel)
+	c.emitBranchOnGreaterThanOrEqualZero("$a0", writeLabel)
+	c.emitLoadInt("$t4", 45) // Minus sign.
+	c.emitSubUnsigned("$t0", "$t0", 1)
+	c.emitStoreByte("$t4", "$t0", 0)
+	c.emitLabel(writeLabel)
+	c.emitMove("$a0", "$t0")
+	c.emitJump(c.useRuntime(runtimeFileString))
+}
+
+// generateFileStringRoutine emits the routine that writes the null terminated string at $a0 to
+// the file $a2, padded on the left with spaces to the width $a1. A bad descriptor or a failed write
+// is reported at line $a3 and exits with 1.
+func (c *CodeGenerator) generateFileStringRoutine() {
+	label := c.prefix + runtimeFileString
+	spaceLabel := label + "_space"
+	lengthLabel := label + "_length"
+	padLabel := label + "_pad"
+	printLabel := label + "_print"
+	failedLabel := label + "_failed"
+	c.addLabelledString(spaceLabel, " ")
+	// WRITELN ends the line with this string.
+	c.data.WriteString(fmt.Sprintf("%s_newline: .byte 10, 0\n", label))
+	c.emitLabel(label)
+	c.emitMove("$t0", "$a0")
+	c.emitMove("$t3", "$a2")
+	c.emitMove("$t4", "$a3")
+	c.emitBranchOnLessThanZero("$t3", failedLabel)
+	// Find the length of the string by looking for the null terminator.
+	c.emitLoadInt("$t6", 0)
+	c.emitLabel(lengthLabel)
+	c.emitAddUnsignedRegister("$t2", "$t0", "$t6")
+	c.emitLoadByte("$t2", "$t2", 0)
+	c.emitBranchOnEqual("$t2", "$zero", padLabel)
+	c.emitAddUnsigned("$t6", "$t6", 1)
+	c.emitJump(lengthLabel)
+	c.emitLabel(padLabel)
+	c.emitSub("$t1", "$a1", "$t6")
+	c.emitLabel(padLabel + "_loop")
+	c.emitBranchOnLessThanOrEqualZero("$t1", printLabel)
+	c.emitMove("$a0", "$t3")
+	c.emitLoadAddress("$a1", spaceLabel)
+	c.emitLoadInt("$a2", 1)
+	c.emitLoadInt("$v0", 15)
+	c.emitSyscall()
+	c.emitBranchOnLessThanZero("$v0", failedLabel)
+	c.emitSubUnsigned("$t1", "$t1", 1)
+	c.emitJump(padLabel + "_loop")
+	c.emitLabel(printLabel)
+	c.emitMove("$a0", "$t3")
+	c.emitMove("$a1", "$t0")
+	c.emitMove("$a2", "$t6")
+	c.emitLoadInt("$v0", 15)
+	c.emitSyscall()
+	c.emitBranchOnLessThanZero("$v0", failedLabel)
+	c.emitJumpReturn()
+	c.emitLabel(failedLabel)
+	c.emitLoadAddress("$a0", c.addString("WRITE failed"))
+	c.emitMove("$a1", "$t4")
+	c.emitLoadInt("$a2", 1)
+	c.emitJump(c.useRuntime(runtimeFail))
+}
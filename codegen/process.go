@@ -0,0 +1,351 @@
+package codegen
+
+import (
+	"fmt"
+
+	"github.com/saicheems/simplelang/ast"
+	"github.com/saicheems/simplelang/symtable"
+)
+
+// processStackSize is the size in bytes of the stack each process of a COBEGIN runs on.
+const processStackSize = 4096
+
+// timeSlice is the number of loop iterations a process runs for before the scheduler switches to
+// the next process.
+const timeSlice = 16
+
+// Labels of the words the scheduler keeps in the data segment. They aren't prefixed so that a
+// program and its modules share them.
+const (
+	currentLabel   = "runtime_current"   // Record of the running process, or 0 for the program.
+	processesLabel = "runtime_processes" // First record of the processes of the COBEGIN.
+	endLabel       = "runtime_end"       // Address just past the last record.
+	sliceLabel     = "runtime_slice"     // Loop iterations left before the next switch.
+	mainLabel      = "runtime_main"      // Record holding the program while processes run.
+)
+
+// Positions of the words in the record of a process. The records of a COBEGIN are kept in the data
+// segment one after another.
+const (
+	processStatus    = iota // One of the process statuses below.
+	processSP               // Stack pointer of the process while it isn't running.
+	processFP               // Frame pointer of the process while it isn't running.
+	processResume           // Address the process continues at when it runs next.
+	processName             // Address of the name of the process, for the deadlock report.
+	processSemaphore        // Address of the semaphore the process is blocked on.
+	processHandler          // Exception handler chain of the process while it isn't running.
+	processLimit            // Lowest address the stack of the process may grow to.
+	processWords            // Number of words in a record.
+)
+
+// Statuses of a process.
+const (
+	processReady = iota
+	processBlocked
+	processDone
+)
+
+// generateCobegin emits a cobegin statement. Each call becomes a process with a record and its own
+// stack. The program saves itself in the main record and jumps to the scheduler, which runs the
+// processes until they're all done and then continues the program after the cobegin.
+func (c *CodeGenerator) generateCobegin(node *ast.Node, syms []*symtable.SymbolTable) {
+	label := c.getNewLabel("cobegin")
+	records := label + "_processes"
+	doneLabel := label + "_done"
+	size := 4 * processWords
+	c.processes = true
+	c.handlers = true
+	c.addWords(records, processWords*len(node.Children))
+	for i, call := range node.Children {
+		stack := fmt.Sprintf("%s_stack_%d", label, i)
+		c.addWords(stack, (c.guard+processStackSize)/4)
+		c.emitLoadAddress("$t0", records)
+		c.emitAddUnsigned("$t0", "$t0", i*size)
+		c.emitStoreWord("$zero", "$t0", 4*processStatus)
+		c.emitStoreWord("$zero", "$t0", 4*processHandler)
+		c.emitLoadAddress("$t1", stack)
+		c.emitAddUnsigned("$t1", "$t1", c.guard)
+		c.emitStoreWord("$t1", "$t0", 4*processLimit)
+		c.emitAddUnsigned("$t1", "$t1", processStackSize-4)
+		c.emitStoreWord("$t1", "$t0", 4*processSP)
+		c.emitLoadAddress("$t1", fmt.Sprintf("%s_start_%d", label, i))
+		c.emitStoreWord("$t1", "$t0", 4*processResume)
+		c.emitLoadAddress("$t1", c.addString(call.Children[0].Tok.Lex))
+		c.emitStoreWord("$t1", "$t0", 4*processName)
+	}
+	// Save the program in the main record.
+	c.emitLoadAddress("$t4", mainLabel)
+	c.emitLoadAddress("$t0", doneLabel)
+	c.generateSaveProcess("$t0")
+	if c.checkStack {
+		c.emitLoadAddress("$t0", stackLimitLabel)
+		c.emitLoadWord("$t0", "$t0", 0)
+		c.emitStoreWord("$t0", "$t4", 4*processLimit)
+	}
+	// The scheduler starts after the current record, so make the last record current.
+	c.emitLoadAddress("$t0", records)
+	c.emitLoadAddress("$t1", processesLabel)
+	c.emitStoreWord("$t0", "$t1", 0)
+	c.emitAddUnsigned("$t0", "$t0", len(node.Children)*size)
+	c.emitLoadAddress("$t1", endLabel)
+	c.emitStoreWord("$t0", "$t1", 0)
+	c.emitSubUnsigned("$t0", "$t0", size)
+	c.emitLoadAddress("$t1", currentLabel)
+	c.emitStoreWord("$t0", "$t1", 0)
+	c.emitJump(c.useRuntime(runtimeSchedule))
+	// Each process starts by calling its procedure on its own stack, with a static link from the
+	// frame of the program. When the procedure returns the process is done.
+	for i, call := range node.Children {
+		key := symtable.Key{symtable.Procedure, call.Children[0].Tok.Lex}
+		n, value := c.getValueFromClosestSymbolTable(key, syms)
+		c.emitLabel(fmt.Sprintf("%s_start_%d", label, i))
+		c.emitLoadAddress("$t0", mainLabel)
+		c.emitLoadWord("$a0", "$t0", 4*processFP)
+		c.emitStoreWord("$a0", "$sp", 0)
+		c.emitSubUnsigned("$sp", "$sp", 4)
+		for i := 0; i < n; i++ {
+			c.emitLoadWord("$a0", "$a0", 4)
+		}
+		c.emitStoreWord("$a0", "$sp", 0)
+		c.emitSubUnsigned("$sp", "$sp", 4)
+		c.emitMove("$fp", "$sp")
+		for i := 0; i < value.NumVars; i++ {
+			c.emitLoadInt("$a0", 0)
+			c.emitStoreWord("$a0", "$sp", 0)
+			c.emitSubUnsigned("$sp", "$sp", 4)
+		}
+		c.emitJumpAndLink(value.Label)
+		c.emitLoadAddress("$t0", currentLabel)
+		c.emitLoadWord("$t0", "$t0", 0)
+		c.emitLoadInt("$t1", processDone)
+		c.emitStoreWord("$t1", "$t0", 4*processStatus)
+		c.emitJump(c.useRuntime(runtimeSchedule))
+	}
+	c.emitLabel(doneLabel)
+}
+
+// generatePreemptionPoint emits a countdown of the time slice of the running process. When it runs
+// out the process gives up the processor to the next one. It clobbers $ra, which procedures keep on
+// the stack anyway.
+func (c *CodeGenerator) generatePreemptionPoint() {
+	continueLabel := c.getNewLabel("preempt")
+	c.emitLoadAddress("$t0", sliceLabel)
+	c.emitLoadWord("$t1", "$t0", 0)
+	c.emitSubUnsigned("$t1", "$t1", 1)
+	c.emitStoreWord("$t1", "$t0", 0)
+	c.emitBranchOnGreaterThanZero("$t1", continueLabel)
+	c.emitJumpAndLink(c.useRuntime(runtimePreempt))
+	c.emitLabel(continueLabel)
+}
+
+// generateWait emits a wait on a semaphore. While the count is 0 the process blocks until a signal
+// on the semaphore wakes it up, then it checks again. Otherwise it takes one from the count.
+func (c *CodeGenerator) generateWait(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	n, value := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Integer, iden.Tok.Lex}, syms)
+	label := c.getNewLabel("wait")
+	takeLabel := label + "_take"
+	c.processes = true
+	c.emitLabel(label)
+	c.loadAddressOfVariable("$a0", n, value)
+	c.emitLoadWord("$t1", "$a0", 0)
+	c.emitBranchOnGreaterThanZero("$t1", takeLabel)
+	c.emitJumpAndLink(c.useRuntime(runtimeBlock))
+	c.emitJump(label)
+	c.emitLabel(takeLabel)
+	c.emitSubUnsigned("$t1", "$t1", 1)
+	c.emitStoreWord("$t1", "$a0", 0)
+}
+
+// generateSignal emits a signal on a semaphore.
+func (c *CodeGenerator) generateSignal(node *ast.Node, syms []*symtable.SymbolTable) {
+	iden := node.Children[0]
+	n, value := c.getValueFromClosestSymbolTable(symtable.Key{symtable.Integer, iden.Tok.Lex}, syms)
+	c.processes = true
+	c.loadAddressOfVariable("$a0", n, value)
+	c.emitJumpAndLink(c.useRuntime(runtimeSignal))
+}
+
+// generateSaveProcess emits the code that saves the running process in the record at $t4 so that it
+// continues at the address in the given register. It clobbers $t1 and $t2.
+func (c *CodeGenerator) generateSaveProcess(resume string) {
+	c.emitStoreWord("$sp", "$t4", 4*processSP)
+	c.emitStoreWord("$fp", "$t4", 4*processFP)
+	c.emitStoreWord(resume, "$t4", 4*processResume)
+	c.emitLoadAddress("$t2", handlerLabel)
+	c.emitLoadWord("$t1", "$t2", 0)
+	c.emitStoreWord("$t1", "$t4", 4*processHandler)
+}
+
+// generateRunProcess emits the code that runs the process in the record at $t4 with a full time
+// slice.
+func (c *CodeGenerator) generateRunProcess() {
+	c.emitLoadAddress("$t0", sliceLabel)
+	c.emitLoadInt("$t1", timeSlice)
+	c.emitStoreWord("$t1", "$t0", 0)
+	c.emitLoadAddress("$t2", handlerLabel)
+	c.emitLoadWord("$t1", "$t4", 4*processHandler)
+	c.emitStoreWord("$t1", "$t2", 0)
+	if c.checkStack {
+		c.emitLoadAddress("$t2", stackLimitLabel)
+		c.emitLoadWord("$t1", "$t4", 4*processLimit)
+		c.emitStoreWord("$t1", "$t2", 0)
+	}
+	c.emitLoadWord("$sp", "$t4", 4*processSP)
+	c.emitLoadWord("$fp", "$t4", 4*processFP)
+	c.emitLoadWord("$t1", "$t4", 4*processResume)
+	c.emitJumpRegister("$t1")
+}
+
+// generateScheduleRoutine emits the scheduler. Starting after the current record it looks for the
+// next process that is ready and runs it. If there is none and some process is blocked, it's a
+// deadlock: the blocked processes are printed and the program exits with 1. Otherwise all of the
+// processes are done and the program continues. It never returns.
+func (c *CodeGenerator) generateScheduleRoutine() {
+	label := c.prefix + runtimeSchedule
+	nextLabel := label + "_next"
+	checkLabel := label + "_check"
+	runLabel := label + "_run"
+	scanLabel := label + "_scan"
+	finishedLabel := label + "_finished"
+	deadlockLabel := label + "_deadlock"
+	reportLabel := label + "_report"
+	skipLabel := label + "_skip"
+	reportedLabel := label + "_reported"
+	c.emitLabel(label)
+	c.emitLoadAddress("$t0", currentLabel)
+	c.emitLoadWord("$t0", "$t0", 0)
+	c.emitLoadAddress("$t1", endLabel)
+	c.emitLoadWord("$t1", "$t1", 0)
+	c.emitLoadAddress("$t2", processesLabel)
+	c.emitLoadWord("$t2", "$t2", 0)
+	c.emitMove("$t4", "$t0")
+	c.emitLabel(nextLabel)
+	c.emitAddUnsigned("$t4", "$t4", 4*processWords)
+	c.emitBranchNotEqual("$t4", "$t1", checkLabel)
+	c.emitMove("$t4", "$t2")
+	c.emitLabel(checkLabel)
+	c.emitLoadWord("$t3", "$t4", 4*processStatus)
+	c.emitBranchOnEqual("$t3", "$zero", runLabel)
+	c.emitBranchNotEqual("$t4", "$t0", nextLabel)
+	// Nothing is ready. Either something is blocked or everything is done.
+	c.emitMove("$t4", "$t2")
+	c.emitLoadInt("$t5", processBlocked)
+	c.emitLabel(scanLabel)
+	c.emitBranchOnEqual("$t4", "$t1", finishedLabel)
+	c.emitLoadWord("$t3", "$t4", 4*processStatus)
+	c.emitBranchOnEqual("$t3", "$t5", deadlockLabel)
+	c.emitAddUnsigned("$t4", "$t4", 4*processWords)
+	c.emitJump(scanLabel)
+	c.emitLabel(finishedLabel)
+	c.emitLoadAddress("$t0", currentLabel)
+	c.emitStoreWord("$zero", "$t0", 0)
+	c.emitLoadAddress("$t4", mainLabel)
+	c.generateRunProcess()
+	c.emitLabel(runLabel)
+	c.emitLoadAddress("$t0", currentLabel)
+	c.emitStoreWord("$t4", "$t0", 0)
+	c.generateRunProcess()
+	c.emitLabel(deadlockLabel)
+	c.emitLoadAddress("$a0", c.addString("deadlock, blocked processes:"))
+	c.emitLoadInt("$v0", 4)
+	c.emitSyscall()
+	c.emitMove("$t4", "$t2")
+	c.emitLabel(reportLabel)
+	c.emitBranchOnEqual("$t4", "$t1", reportedLabel)
+	c.emitLoadWord("$t3", "$t4", 4*processStatus)
+	c.emitBranchNotEqual("$t3", "$t5", skipLabel)
+	c.emitLoadInt("$a0", 32) // Prints space character.
+	c.emitLoadInt("$v0", 11)
+	c.emitSyscall()
+	c.emitLoadWord("$a0", "$t4", 4*processName)
+	c.emitLoadInt("$v0", 4)
+	c.emitSyscall()
+	c.emitLabel(skipLabel)
+	c.emitAddUnsigned("$t4", "$t4", 4*processWords)
+	c.emitJump(reportLabel)
+	c.emitLabel(reportedLabel)
+	c.emitLoadAddress("$a0", c.addString(""))
+	c.emitLoadInt("$a2", 1)
+	c.emitJump(c.useRuntime(runtimeAbort))
+}
+
+// generatePreemptRoutine emits the routine that ends the time slice of the running process. The
+// process stays ready and continues at $ra the next time it runs. The program itself is never
+// preempted, so the routine just starts a new time slice and returns if no process is running.
+func (c *CodeGenerator) generatePreemptRoutine() {
+	label := c.prefix + runtimePreempt
+	saveLabel := label + "_save"
+	c.emitLabel(label)
+	c.emitLoadAddress("$t0", sliceLabel)
+	c.emitLoadInt("$t1", timeSlice)
+	c.emitStoreWord("$t1", "$t0", 0)
+	c.emitLoadAddress("$t0", currentLabel)
+	c.emitLoadWord("$t4", "$t0", 0)
+	c.emitBranchNotEqual("$t4", "$zero", saveLabel)
+	c.emitJumpReturn()
+	c.emitLabel(saveLabel)
+	c.emitStoreWord("$zero", "$t4", 4*processStatus)
+	c.generateSaveProcess("$ra")
+	c.emitJump(c.useRuntime(runtimeSchedule))
+}
+
+// generateBlockRoutine emits the routine that blocks the running process on the semaphore at $a0.
+// It continues at $ra once a signal on the semaphore wakes it up. If the program itself waits there
+// is nothing that could signal it, so that's a deadlock.
+func (c *CodeGenerator) generateBlockRoutine() {
+	label := c.prefix + runtimeBlock
+	saveLabel := label + "_save"
+	c.emitLabel(label)
+	c.emitLoadAddress("$t0", currentLabel)
+	c.emitLoadWord("$t4", "$t0", 0)
+	c.emitBranchNotEqual("$t4", "$zero", saveLabel)
+	c.emitLoadAddress("$a0", c.addString("deadlock, the program is blocked"))
+	c.emitLoadInt("$a2", 1)
+	c.emitJump(c.useRuntime(runtimeAbort))
+	c.emitLabel(saveLabel)
+	c.emitLoadInt("$t1", processBlocked)
+	c.emitStoreWord("$t1", "$t4", 4*processStatus)
+	c.emitStoreWord("$a0", "$t4", 4*processSemaphore)
+	c.generateSaveProcess("$ra")
+	c.emitJump(c.useRuntime(runtimeSchedule))
+}
+
+// generateSignalRoutine emits the routine that signals the semaphore at $a0. It adds one to the
+// count and makes every process blocked on the semaphore ready, so they can check the count again.
+func (c *CodeGenerator) generateSignalRoutine() {
+	label := c.prefix + runtimeSignal
+	loopLabel := label + "_loop"
+	nextLabel := label + "_next"
+	doneLabel := label + "_done"
+	c.emitLabel(label)
+	c.emitLoadWord("$t0", "$a0", 0)
+	c.emitAddUnsigned("$t0", "$t0", 1)
+	c.emitStoreWord("$t0", "$a0", 0)
+	c.emitLoadAddress("$t1", processesLabel)
+	c.emitLoadWord("$t1", "$t1", 0)
+	c.emitLoadAddress("$t2", endLabel)
+	c.emitLoadWord("$t2", "$t2", 0)
+	c.emitLoadInt("$t5", processBlocked)
+	c.emitLabel(loopLabel)
+	c.emitBranchOnEqual("$t1", "$t2", doneLabel)
+	c.emitLoadWord("$t3", "$t1", 4*processStatus)
+	c.emitBranchNotEqual("$t3", "$t5", nextLabel)
+	c.emitLoadWord("$t3", "$t1", 4*processSemaphore)
+	c.emitBranchNotEqual("$t3", "$a0", nextLabel)
+	c.emitStoreWord("$zero", "$t1", 4*processStatus)
+	c.emitLabel(nextLabel)
+	c.emitAddUnsigned("$t1", "$t1", 4*processWords)
+	c.emitJump(loopLabel)
+	c.emitLabel(doneLabel)
+	c.emitJumpReturn()
+}
+
+// addSchedulerWords places the words the scheduler keeps in the data segment.
+func (c *CodeGenerator) addSchedulerWords() {
+	c.addWords(currentLabel, 1)
+	c.addWords(processesLabel, 1)
+	c.addWords(endLabel, 1)
+	c.addWords(sliceLabel, 1)
+	c.addWords(mainLabel, processWords)
+}
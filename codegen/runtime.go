@@ -0,0 +1,228 @@
+package codegen
+
+// Labels of the runtime routines. A routine is only emitted if the generated code uses it.
+const (
+	runtimeWriteInt    = "runtime_write_int"    // Prints $a0 right aligned to a width of $a1.
+	runtimeWriteString = "runtime_write_string" // Prints the string at $a0 right aligned to $a1.
+	runtimeReadInt     = "runtime_read_int"     // Reads an integer from file $a0 for line $a1.
+	runtimeFileInt     = "runtime_file_int"     // Writes $a0 to file $a2 like runtimeWriteInt.
+	runtimeFileString  = "runtime_file_string"  // Writes $a0 to file $a2 like runtimeWriteString.
+	runtimeConcat      = "runtime_concat"       // Returns a new string of $a0 followed by $a1.
+	runtimeCompare     = "runtime_compare"      // Compares the strings $a0 and $a1.
+	runtimeRaise       = "runtime_raise"        // Raises exception $a0 from line $a1.
+	runtimeSchedule    = "runtime_schedule"     // Runs the next process that is ready.
+	runtimePreempt     = "runtime_preempt"      // Ends the time slice of the running process.
+	runtimeBlock       = "runtime_block"        // Blocks the running process on semaphore $a0.
+	runtimeSignal      = "runtime_signal"       // Signals semaphore $a0.
+	runtimeFail        = "runtime_fail"         // Prints $a0 at line $a1 and exits with $a2.
+	runtimeAbort       = "runtime_abort"        // Prints $a0 and exits with $a2.
+)
+
+// stackLimitLabel is the label of the word holding the lowest address the stack may grow to.
+const stackLimitLabel = "runtime_stack_limit"
+
+// handlerLabel is the label of the word holding the address of the innermost exception handler
+// record, or 0 if no TRY is active. It isn't prefixed so that a program and its modules share it.
+const handlerLabel = "runtime_handler"
+
+// useRuntime marks a runtime routine as used by the generated code and returns its label. Modules
+// have their own copy of each routine they use, so the label has the module prefix.
+func (c *CodeGenerator) useRuntime(name string) string {
+	c.runtime[name] = true
+	return c.prefix + name
+}
+
+// generateRuntime emits every runtime routine the generated code has used. The routines are leaf
+// routines: they are reached with a jal (or a jump if they never return) and only clobber the $a,
+// $t and $v registers.
+func (c *CodeGenerator) generateRuntime() {
+	if c.runtime[runtimeWriteInt] {
+		c.generateWriteIntRoutine()
+	}
+	if c.runtime[runtimeWriteString] {
+		c.generateWriteStringRoutine()
+	}
+	if c.runtime[runtimeReadInt] {
+		c.generateReadIntRoutine()
+	}
+	// Writing an integer to a file ends in the routine that writes strings, so it goes first.
+	if c.runtime[runtimeFileInt] {
+		c.generateFileIntRoutine()
+	}
+	if c.runtime[runtimeFileString] {
+		c.generateFileStringRoutine()
+	}
+	if c.runtime[runtimeConcat] {
+		c.generateConcatRoutine()
+	}
+	if c.runtime[runtimeCompare] {
+		c.generateCompareRoutine()
+	}
+	if c.runtime[runtimeRaise] {
+		c.generateRaiseRoutine()
+	}
+	if c.runtime[runtimePreempt] {
+		c.generatePreemptRoutine()
+	}
+	if c.runtime[runtimeBlock] {
+		c.generateBlockRoutine()
+	}
+	if c.runtime[runtimeSignal] {
+		c.generateSignalRoutine()
+	}
+	// The other scheduler routines jump to the scheduler, so it goes after them.
+	if c.runtime[runtimeSchedule] || c.runtime[runtimePreempt] || c.runtime[runtimeBlock] {
+		c.generateScheduleRoutine()
+	}
+	if c.runtime[runtimeFail] {
+		c.generateFailRoutine()
+	}
+	if c.runtime[runtimeAbort] {
+		c.generateAbortRoutine()
+	}
+}
+
+// generateWriteIntRoutine emits the routine that prints an integer padded to a width. It counts
+// the characters needed to print the integer (including the sign) and prints enough spaces to make
+// up the width before printing the integer.
+func (c *CodeGenerator) generateWriteIntRoutine() {
+	label := c.prefix + runtimeWriteInt
+	countLabel := label + "_count"
+	signLabel := label + "_sign"
+	printLabel := label + "_print"
+	c.emitLabel(label)
+	c.emitMove("$t0", "$a0")
+	// Count the digits by dividing by 10 until there's nothing left.
+	c.emitLoadInt("$t1", 1)
+	c.emitLoadInt("$t3", 10)
+	c.emitDiv("$t0", "$t3")
+	c.emitMoveFromLo("$t2")
+	c.emitLabel(countLabel)
+	c.emitBranchOnEqual("$t2", "$zero", signLabel)
+	c.emitAddUnsigned("$t1", "$t1", 1)
+	c.emitDiv("$t2", "$t3")
+	c.emitMoveFromLo("$t2")
+	c.emitJump(countLabel)
+	c.emitLabel(signLabel)
+	// Negative numbers need room for the minus sign.
+	c.emitBranchOnGreaterThanOrEqualZero("$t0", label+"_pad")
+	c.emitAddUnsigned("$t1", "$t1", 1)
+	c.generatePadding(label, printLabel)
+	c.emitLabel(printLabel)
+	c.emitMove("$a0", "$t0")
+	c.emitLoadInt("$v0", 1)
+	c.emitSyscall()
+	c.emitJumpReturn()
+}
+
+// generateWriteStringRoutine emits the routine that prints a null terminated string padded to a
+// width.
+func (c *CodeGenerator) generateWriteStringRoutine() {
+	label := c.prefix + runtimeWriteString
+	lengthLabel := label + "_length"
+	printLabel := label + "_print"
+	c.emitLabel(label)
+	c.emitMove("$t0", "$a0")
+	// Find the length of the string by looking for the null terminator.
+	c.emitLoadInt("$t1", 0)
+	c.emitLabel(lengthLabel)
+	c.emitAddUnsignedRegister("$t2", "$t0", "$t1")
+	c.emitLoadByte("$t2", "$t2", 0)
+	c.emitBranchOnEqual("$t2", "$zero", label+"_pad")
+	c.emitAddUnsigned("$t1", "$t1", 1)
+	c.emitJump(lengthLabel)
+	c.generatePadding(label, printLabel)
+	c.emitLabel(printLabel)
+	c.emitMove("$a0", "$t0")
+	c.emitLoadInt("$v0", 4)
+	c.emitSyscall()
+	c.emitJumpReturn()
+}
+
+// generatePadding emits a loop that prints $a1 - $t1 spaces and then continues at the done label.
+// The loop is labelled with the base label followed by _pad. It clobbers $a0, $t1 and $v0.
+func (c *CodeGenerator) generatePadding(base string, done string) {
+	padLabel := base + "_pad"
+	loopLabel := base + "_pad_loop"
+	c.emitLabel(padLabel)
+	c.emitSub("$t1", "$a1", "$t1")
+	c.emitLabel(loopLabel)
+	c.emitBranchOnLessThanOrEqualZero("$t1", done)
+	c.emitLoadInt("$a0", 32) // Prints space character.
+	c.emitLoadInt("$v0", 11)
+	c.emitSyscall()
+	c.emitSubUnsigned("$t1", "$t1", 1)
+	c.emitJump(loopLabel)
+}
+
+// generateRaiseRoutine emits the routine that raises the exception code in $a0 from line $a1. A TRY
+// pushes a handler record of three words onto the stack: the previous record, the frame pointer and
+// the address of its handler. The routine unlinks the innermost record, restores the frame and
+// stack pointers the TRY had and jumps to the handler with $a0 and $a1 intact. If there is no
+// record the exception is uncaught, so it prints the code and the line and exits with 1. It never
+// returns.
+func (c *CodeGenerator) generateRaiseRoutine() {
+	label := c.prefix + runtimeRaise
+	uncaughtLabel := label + "_uncaught"
+	c.emitLabel(label)
+	c.emitLoadAddress("$t0", handlerLabel)
+	c.emitLoadWord("$t1", "$t0", 0)
+	c.emitBranchOnEqual("$t1", "$zero", uncaughtLabel)
+	c.emitLoadWord("$t2", "$t1", 0)
+	c.emitStoreWord("$t2", "$t0", 0)
+	c.emitLoadWord("$fp", "$t1", -4)
+	c.emitLoadWord("$t2", "$t1", -8)
+	c.emitSubUnsigned("$sp", "$t1", 12)
+	c.emitJumpRegister("$t2")
+	c.emitLabel(uncaughtLabel)
+	c.emitMove("$t0", "$a0")
+	c.emitLoadAddress("$a0", c.addString("uncaught exception "))
+	c.emitLoadInt("$v0", 4)
+	c.emitSyscall()
+	c.emitMove("$a0", "$t0")
+	c.emitLoadInt("$v0", 1)
+	c.emitSyscall()
+	// The fail routine finishes the message with the line number.
+	c.emitLoadAddress("$a0", c.addString(""))
+	c.emitLoadInt("$a2", 1)
+	c.emitJump(c.useRuntime(runtimeFail))
+}
+
+// generateFailRoutine emits the routine that reports a runtime failure. It prints the message at
+// $a0 followed by the line number in $a1 and exits with the status in $a2 using the exit2 syscall.
+// It never returns, so it can be reached with a plain jump.
+func (c *CodeGenerator) generateFailRoutine() {
+	label := c.prefix + runtimeFail
+	atLabel := label + "_at"
+	c.addLabelledString(atLabel, " at line ")
+	c.emitLabel(label)
+	c.emitLoadInt("$v0", 4)
+	c.emitSyscall()
+	c.emitLoadAddress("$a0", atLabel)
+	c.emitSyscall()
+	c.emitMove("$a0", "$a1")
+	c.emitLoadInt("$v0", 1)
+	c.emitSyscall()
+	c.emitLoadInt("$a0", 10) // Prints newline character.
+	c.emitLoadInt("$v0", 11)
+	c.emitSyscall()
+	c.emitMove("$a0", "$a2")
+	c.emitLoadInt("$v0", 17)
+	c.emitSyscall()
+}
+
+// generateAbortRoutine emits the routine that reports a runtime failure that has no line number.
+// It prints the message at $a0 and exits with the status in $a2 using the exit2 syscall. It never
+// returns.
+func (c *CodeGenerator) generateAbortRoutine() {
+	label := c.prefix + runtimeAbort
+	c.emitLabel(label)
+	c.emitLoadInt("$v0", 4)
+	c.emitSyscall()
+	c.emitLoadInt("$a0", 10) // Prints newline character.
+	c.emitLoadInt("$v0", 11)
+	c.emitSyscall()
+	c.emitMove("$a0", "$a2")
+	c.emitLoadInt("$v0", 17)
+	c.emitSyscall()
+}
@@ -0,0 +1,142 @@
+package codegen
+
+import (
+	"fmt"
+
+	"github.com/saicheems/simplelang/ast"
+	"github.com/saicheems/simplelang/symtable"
+)
+
+// addStringValue places a STRING value in the data segment and returns its label. The length of the
+// string is in the word at the label and the null terminated characters follow it.
+func (c *CodeGenerator) addStringValue(s string) string {
+	label := c.getNewLabel("string")
+	c.data.WriteString(fmt.Sprintf(".align 2\n%s: .word %d\n", label, len(s)))
+	c.addLabelledString(label+"_chars", s)
+	return label
+}
+
+// generateEmptyStringCheck emits a check that replaces a null address in register t with the
+// address of the empty string. STRING variables start out as 0 like every other variable, so this
+// makes sure the runtime routines only ever see a string.
+func (c *CodeGenerator) generateEmptyStringCheck(t string) {
+	if c.emptyString == "" {
+		c.emptyString = c.addStringValue("")
+	}
+	label := c.getNewLabel("empty")
+	c.emitBranchNotEqual(t, "$zero", label)
+	c.emitLoadAddress(t, c.emptyString)
+	c.emitLabel(label)
+}
+
+// generateCharacter emits the character of a string given by an index node and places it on the
+// stack. The index is checked against the length of the string unless the code is unsafe.
+func (c *CodeGenerator) generateCharacter(node *ast.Node, syms []*symtable.SymbolTable) {
+	c.generateExpression(node.Children[0], syms)
+	c.generateExpression(node.Children[1], syms)
+	// Pop the index and the address of the string off of the stack.
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t0", "$sp", 0)
+	c.emitAddUnsigned("$sp", "$sp", 4)
+	c.emitLoadWord("$t1", "$sp", 0)
+	if !c.opt.Unsafe {
+		label := c.getNewLabel("range")
+		c.emitLoadWord("$t2", "$t1", 0)
+		// Negative indices are bigger than the length when compared unsigned.
+		c.emitSetOnLessThanUnsigned("$t2", "$t0", "$t2")
+		c.emitBranchNotEqual("$t2", "$zero", label)
+		c.emitLoadInt("$a2", 1)
+		c.generateFail("value out of range", node.Tok)
+		c.emitLabel(label)
+	}
+	c.emitAddUnsignedRegister("$t0", "$t1", "$t0")
+	c.emitLoadByteUnsigned("$t0", "$t0", 4)
+	c.emitStoreWord("$t0", "$sp", 0)
+	c.emitSubUnsigned("$sp", "$sp", 4)
+}
+
+// generateConcatRoutine emits the routine that joins the strings at $a0 and $a1 and returns the
+// address of the new string in $v0. The new string is allocated on the heap with sbrk, rounded up
+// to a whole number of words so the next string starts on a word.
+func (c *CodeGenerator) generateConcatRoutine() {
+	label := c.prefix + runtimeConcat
+	leftLabel := label + "_left"
+	rightLabel := label + "_right"
+	doneLabel := label + "_done"
+	c.emitLabel(label)
+	c.emitMove("$t3", "$a0")
+	c.emitLoadWord("$t0", "$a0", 0)
+	c.emitLoadWord("$t1", "$a1", 0)
+	c.emitAddUnsignedRegister("$t2", "$t0", "$t1")
+	// Room for the length, the characters and the null terminator.
+	c.emitAddUnsigned("$a0", "$t2", 8)
+	c.emitLoadInt("$t4", -4)
+	c.emitAnd("$a0", "$a0", "$t4")
+	c.emitLoadInt("$v0", 9)
+	c.emitSyscall()
+	c.emitStoreWord("$t2", "$v0", 0)
+	c.emitAddUnsigned("$t4", "$v0", 4)
+	// Copy the characters of the left string, then the ones of the right string.
+	c.emitAddUnsigned("$t5", "$t3", 4)
+	c.emitLabel(leftLabel)
+	c.emitBranchOnEqual("$t0", "$zero", rightLabel)
+	c.emitLoadByte("$t6", "$t5", 0)
+	c.emitStoreByte("$t6", "$t4", 0)
+	c.emitAddUnsigned("$t4", "$t4", 1)
+	c.emitAddUnsigned("$t5", "$t5", 1)
+	c.emitSubUnsigned("$t0", "$t0", 1)
+	c.emitJump(leftLabel)
+	c.emitLabel(rightLabel)
+	c.emitAddUnsigned("$t5", "$a1", 4)
+	c.emitLabel(rightLabel + "_loop")
+	c.emitBranchOnEqual("$t1", "$zero", doneLabel)
+	c.emitLoadByte("$t6", "$t5", 0)
+	c.emitStoreByte("$t6", "$t4", 0)
+	c.emitAddUnsigned("$t4", "$t4", 1)
+	c.emitAddUnsigned("$t5", "$t5", 1)
+	c.emitSubUnsigned("$t1", "$t1", 1)
+	c.emitJump(rightLabel + "_loop")
+	c.emitLabel(doneLabel)
+	c.emitStoreByte("$zero", "$t4", 0)
+	c.emitJumpReturn()
+}
+
+// generateCompareRoutine emits the routine that compares the strings at $a0 and $a1 character by
+// character and returns -1, 0 or 1 in $v0 if the first is less than, equal to or greater than the
+// second. A string that runs out first is the lesser one.
+func (c *CodeGenerator) generateCompareRoutine() {
+	label := c.prefix + runtimeCompare
+	loopLabel := label + "_loop"
+	endLabel := label + "_end"
+	lessLabel := label + "_less"
+	greaterLabel := label + "_greater"
+	c.emitLabel(label)
+	c.emitLoadWord("$t0", "$a0", 0)
+	c.emitLoadWord("$t1", "$a1", 0)
+	c.emitAddUnsigned("$a0", "$a0", 4)
+	c.emitAddUnsigned("$a1", "$a1", 4)
+	c.emitLabel(loopLabel)
+	c.emitBranchOnEqual("$t0", "$zero", endLabel)
+	c.emitBranchOnEqual("$t1", "$zero", greaterLabel)
+	c.emitLoadByteUnsigned("$t2", "$a0", 0)
+	c.emitLoadByteUnsigned("$t3", "$a1", 0)
+	c.emitSetOnLessThanUnsigned("$t4", "$t2", "$t3")
+	c.emitBranchNotEqual("$t4", "$zero", lessLabel)
+	c.emitBranchNotEqual("$t2", "$t3", greaterLabel)
+	c.emitAddUnsigned("$a0", "$a0", 1)
+	c.emitAddUnsigned("$a1", "$a1", 1)
+	c.emitSubUnsigned("$t0", "$t0", 1)
+	c.emitSubUnsigned("$t1", "$t1", 1)
+	c.emitJump(loopLabel)
+	c.emitLabel(endLabel)
+	// The first string has run out, so they're equal if the second has too.
+	c.emitLoadInt("$v0", 0)
+	c.emitBranchNotEqual("$t1", "$zero", lessLabel)
+	c.emitJumpReturn()
+	c.emitLabel(lessLabel)
+	c.emitLoadInt("$v0", -1)
+	c.emitJumpReturn()
+	c.emitLabel(greaterLabel)
+	c.emitLoadInt("$v0", 1)
+	c.emitJumpReturn()
+}
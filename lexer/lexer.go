@@ -4,18 +4,62 @@ package lexer
 import (
 	"bufio"
 	"bytes"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/saicheems/simplelang/token"
 )
 
+// Dialect is a variant of the source language accepted by the Lexer.
+type Dialect int
+
+// Dialects of the source language.
+const (
+	Standard Dialect = iota // Keywords are upper case, ex. BEGIN.
+	Wirth                   // Keywords may be in any case, ex. begin as in Wirth's PL/0.
+	Caseless                // Keywords and identifiers may be in any case. Identifiers are lowered.
+)
+
+// dialectNames are the names of the dialects, indexed by Dialect.
+var dialectNames = []string{"standard", "wirth", "caseless"}
+
+// String returns the name of the dialect, ex. "wirth".
+func (d Dialect) String() string {
+	return dialectNames[d]
+}
+
+// ParseDialect returns the dialect with the given name. The bool is false if there isn't one.
+func ParseDialect(name string) (Dialect, bool) {
+	for i, dialectName := range dialectNames {
+		if name == dialectName {
+			return Dialect(i), true
+		}
+	}
+	return Standard, false
+}
+
 // Lexer implements the lexical scanning phase of the compilation.
 type Lexer struct {
+	source
+	dialect  Dialect         // Dialect of the source language.
+	nested   bool            // Whether or not block comments nest.
+	res      map[string]int  // Map of reserved keywords.
+	peek     byte            // Peek byte.
+	stack    []source        // Sources suspended by an INCLUDE, innermost last.
+	included map[string]bool // Absolute paths of every file that has been included.
+	incPath  []string        // Directories searched for included files.
+}
+
+// source is an input stream and the position of the Lexer in it.
+type source struct {
 	rd   *bufio.Reader
-	res  map[string]int // Map of reserved keywords.
-	peek byte           // Peek byte.
-	ln   int            // Current line number in input stream.
+	ln   int    // Current line number in input stream.
+	eof  bool   // Set once a read has hit the end of the input stream.
+	file string // Name of the file for error messages. Empty if it isn't a file.
+	path string // Absolute path of the file. Empty if it isn't a file.
 }
 
 // New returns a new Lexer given a File. The file is opened and a bufio.Reader is created to read
@@ -23,7 +67,10 @@ type Lexer struct {
 func New(f *os.File) *Lexer {
 	l := new(Lexer)
 	l.rd = bufio.NewReader(f)
+	l.file = f.Name()
+	l.path, _ = filepath.Abs(f.Name())
 	l.res = make(map[string]int)
+	l.included = map[string]bool{l.path: true}
 	l.loadKeywords()
 	return l
 }
@@ -33,23 +80,56 @@ func NewFromString(s string) *Lexer {
 	l := new(Lexer)
 	l.rd = bufio.NewReader(strings.NewReader(s))
 	l.res = make(map[string]int)
+	l.included = make(map[string]bool)
 	l.loadKeywords()
 	return l
 }
 
+// SetDialect sets the dialect of the source language. It should be called before Scan.
+func (l *Lexer) SetDialect(d Dialect) {
+	l.dialect = d
+}
+
+// Dialect returns the dialect of the source language.
+func (l *Lexer) Dialect() Dialect {
+	return l.dialect
+}
+
+// SetNestedComments sets whether or not block comments nest, ex. (* a (* b *) c *) is one comment
+// when they do. It should be called before Scan.
+func (l *Lexer) SetNestedComments(nested bool) {
+	l.nested = nested
+}
+
+// SetIncludePath sets the directories searched in order for included files that aren't found next
+// to the file including them. It should be called before Scan.
+func (l *Lexer) SetIncludePath(dirs []string) {
+	l.incPath = dirs
+}
+
 // Scan returns the next valid token from the input stream. If a lexing error occurs, it returns an
 // Token with the tag Error. If the input stream is completed then token.EOF is returned. Otherwise
 // token.UnexpectedChar is returned..
 func (l *Lexer) Scan() *token.Token {
 	if l.readCharAndWhitespace() != nil {
-		return token.EOF
+		return l.scanEOF()
 	}
-	if l.scanComments() != nil {
-		return token.EOF
+	if tok, err := l.scanComments(); err != nil {
+		return l.scanEOF()
+	} else if tok != nil {
+		return tok
 	}
-	tok := token.New(l.ln)
+	tok := token.New(l.ln, l.file)
 	if l.peek == '.' {
 		tok.Tag = token.Period
+		// We won't do anything about an error here.
+		m, _ := l.readCharAndMatch('.')
+		if m {
+			tok.Tag = token.DotDot
+			return tok
+		} else {
+			l.unreadChar()
+		}
 		return tok
 	} else if l.peek == ',' {
 		tok.Tag = token.Comma
@@ -87,15 +167,39 @@ func (l *Lexer) Scan() *token.Token {
 		return tok
 	} else if l.peek == '*' {
 		tok.Tag = token.Times
+		// We won't do anything about an error here.
+		m, _ := l.readCharAndMatch('=')
+		if m {
+			tok.Tag = token.TimesAssignment
+			return tok
+		} else {
+			l.unreadChar()
+		}
 		return tok
 	} else if l.peek == '/' {
 		tok.Tag = token.Divide
 		return tok
 	} else if l.peek == '+' {
 		tok.Tag = token.Plus
+		// We won't do anything about an error here.
+		m, _ := l.readCharAndMatch('=')
+		if m {
+			tok.Tag = token.PlusAssignment
+			return tok
+		} else {
+			l.unreadChar()
+		}
 		return tok
 	} else if l.peek == '-' {
 		tok.Tag = token.Minus
+		// We won't do anything about an error here.
+		m, _ := l.readCharAndMatch('=')
+		if m {
+			tok.Tag = token.MinusAssignment
+			return tok
+		} else {
+			l.unreadChar()
+		}
 		return tok
 	} else if l.peek == '{' {
 		tok.Tag = token.LeftCurlyBrace
@@ -103,6 +207,12 @@ func (l *Lexer) Scan() *token.Token {
 	} else if l.peek == '}' {
 		tok.Tag = token.RightCurlyBrace
 		return tok
+	} else if l.peek == '[' {
+		tok.Tag = token.LeftBracket
+		return tok
+	} else if l.peek == ']' {
+		tok.Tag = token.RightBracket
+		return tok
 	} else if l.peek == '(' {
 		tok.Tag = token.LeftParen
 		return tok
@@ -113,6 +223,7 @@ func (l *Lexer) Scan() *token.Token {
 		tok.Tag = token.Exclamation
 		return tok
 	} else if l.peek == ':' {
+		tok.Tag = token.Colon
 		// We won't do anything about an error here.
 		m, _ := l.readCharAndMatch('=')
 		if m {
@@ -121,6 +232,9 @@ func (l *Lexer) Scan() *token.Token {
 		} else {
 			l.unreadChar()
 		}
+		return tok
+	} else if l.peek == '"' {
+		return l.scanString(tok)
 	}
 	if isAlpha(l.peek) {
 		var strBuf bytes.Buffer
@@ -136,100 +250,268 @@ func (l *Lexer) Scan() *token.Token {
 			}
 		}
 		lexeme := strBuf.String()
+		keyword := lexeme
+		if l.dialect != Standard {
+			keyword = strings.ToUpper(lexeme)
+		}
 		tok.Tag = token.Identifier
-		if l.res[lexeme] != 0 {
-			tok.Tag = l.res[lexeme]
+		if l.res[keyword] != 0 {
+			tok.Tag = l.res[keyword]
 		}
 		// We won't set the lexeme of the token if it's a keyword.
 		if tok.Tag == token.Identifier {
 			tok.Lex = lexeme
+			if l.dialect == Caseless {
+				tok.Lex = strings.ToLower(lexeme)
+			}
+		} else if tok.Tag == token.Include {
+			return l.scanInclude(tok)
 		}
 		return tok
 	}
 	if isDigit(l.peek) {
-		v := 0
-		for {
-			v = 10*v + convertCharDigitToInt(l.peek)
-			err := l.readChar()
-			if err != nil {
-				break
-			}
-			if !isDigit(l.peek) {
-				l.unreadChar()
-				break
+		return l.scanNumber(tok)
+	}
+	return token.UnexpectedChar
+}
+
+// scanNumber scans a number literal starting at peek and returns it as an Integer or Real token.
+// Integers may be written in hex (0x1F) or binary (0b101) and their digits may be separated by
+// underscores (1_000_000). It returns an error token at the literal if the literal is malformed or
+// an integer doesn't fit in a 32-bit signed word.
+func (l *Lexer) scanNumber(tok *token.Token) *token.Token {
+	base := 10
+	if next, err := l.rd.Peek(1); err == nil && l.peek == '0' {
+		if next[0] == 'x' || next[0] == 'X' {
+			base = 16
+		} else if next[0] == 'b' || next[0] == 'B' {
+			base = 2
+		}
+		if base != 10 {
+			l.readChar()
+			if l.readChar() != nil {
+				return l.newError(tok, "malformed number")
 			}
 		}
-		tok.Tag = token.Integer
-		tok.Val = v
+	}
+	digits, ok := l.scanDigits(base)
+	if !ok {
+		return l.newError(tok, "malformed number")
+	}
+	// A period followed by a digit makes it a real. Otherwise the period is left alone since it
+	// might end the program.
+	if next, err := l.rd.Peek(2); base == 10 && err == nil && next[0] == '.' && isDigit(next[1]) {
+		l.readChar()
+		l.readChar()
+		fraction, ok := l.scanDigits(10)
+		if !ok {
+			return l.newError(tok, "malformed number")
+		}
+		tok.Tag = token.Real
+		tok.Rval, _ = strconv.ParseFloat(digits+"."+fraction, 64)
 		return tok
 	}
-	return token.UnexpectedChar
+	v, err := strconv.ParseInt(digits, base, 32)
+	if err != nil {
+		return l.newError(tok, "number doesn't fit in 32 bits")
+	}
+	tok.Tag = token.Integer
+	tok.Val = int(v)
+	return tok
 }
 
-// scanComments checks for block comments or line comments and eats input until they are terminated.
-// It returns an io.EOF error if EOF is encountered. Otherwise it returns nil. Otherwise it returns
-// nil. Otherwise it returns nil. Otherwise it returns nil.
-func (l *Lexer) scanComments() error {
-	if l.peek == '/' {
-		match, err := l.readCharAndMatch('*')
-		if err != nil {
-			// We'll return nil in this case so we can pick up the divide token...
-			return nil
-		}
-		if match {
-			for {
-				match, err := l.readCharAndMatch('*')
-				if err != nil {
-					return err
-				}
-				if match {
-					match, err := l.readCharAndMatch('/')
-					if err != nil {
-						return err
-					}
-					if match {
-						// Skip ahead to the next
-						// non-whitespace peek char.
-						err := l.readCharAndWhitespace()
-						if err != nil {
-							return err
-						}
-						break
-					} else {
-						l.unreadChar()
-					}
-				}
+// scanDigits scans the digits of a number in the given base starting at peek and returns them
+// without the underscores separating them. Underscores must be between two digits. The bool is
+// false if there are no digits or an underscore is out of place.
+func (l *Lexer) scanDigits(base int) (string, bool) {
+	var strBuf bytes.Buffer
+	underscore := false
+	for {
+		if l.peek == '_' {
+			if strBuf.Len() == 0 || underscore {
+				return "", false
 			}
+			underscore = true
+		} else if isDigitInBase(l.peek, base) {
+			strBuf.WriteByte(l.peek)
+			underscore = false
 		} else {
 			l.unreadChar()
-			match, err := l.readCharAndMatch('/')
-			if err != nil {
-				return err
-			}
-			if match {
-				for {
-					err := l.readChar()
-					if err != nil {
-						return err
-					}
-					if l.peek == '\n' {
-						err := l.readCharAndWhitespace()
-						if err != nil {
-							return err
-						}
-						break
-					}
-				}
-			} else {
-				l.unreadChar()
-				// We need to reset the state so division op can be read.
-				l.peek = '/'
+			break
+		}
+		if l.readChar() != nil {
+			break
+		}
+	}
+	return strBuf.String(), strBuf.Len() > 0 && !underscore
+}
+
+// scanEOF is called when the input stream ends. If the input stream was included, scanning resumes
+// after the INCLUDE in the file that included it. Otherwise it returns token.EOF.
+func (l *Lexer) scanEOF() *token.Token {
+	if len(l.stack) == 0 {
+		return token.EOF
+	}
+	l.source = l.stack[len(l.stack)-1]
+	l.stack = l.stack[:len(l.stack)-1]
+	return l.Scan()
+}
+
+// scanInclude handles an INCLUDE directive given its token. The file named by the string after it
+// is scanned in place of the directive. Each file is only included once, so including a file again
+// does nothing. It returns the first token of the included file, or an error token if the file
+// can't be found or includes itself.
+func (l *Lexer) scanInclude(tok *token.Token) *token.Token {
+	name := l.Scan()
+	if name.Tag != token.String {
+		return l.newError(tok, "INCLUDE needs a file name in quotes")
+	}
+	path := l.findInclude(name.Lex)
+	if path == "" {
+		return l.newError(tok, "can't find included file \""+name.Lex+"\"")
+	}
+	abs, _ := filepath.Abs(path)
+	if abs == l.path {
+		return l.newError(tok, "\""+name.Lex+"\" includes itself")
+	}
+	for _, src := range l.stack {
+		if abs == src.path {
+			return l.newError(tok, "\""+name.Lex+"\" is included in a cycle")
+		}
+	}
+	if l.included[abs] {
+		return l.Scan()
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return l.newError(tok, "can't read included file \""+name.Lex+"\"")
+	}
+	l.included[abs] = true
+	l.stack = append(l.stack, l.source)
+	l.source = source{rd: bufio.NewReader(bytes.NewReader(b)), file: path, path: abs}
+	return l.Scan()
+}
+
+// newError returns an error token for a lexical error at the given token. The message says which
+// dialect is being scanned, since that decides what counts as a keyword.
+func (l *Lexer) newError(tok *token.Token, msg string) *token.Token {
+	return token.NewError(tok.Ln, tok.File, msg+" ("+l.dialect.String()+" dialect)")
+}
+
+// findInclude returns the path of an included file. The file is looked for next to the file
+// including it and then in each directory of the include path. It returns an empty string if the
+// file can't be found.
+func (l *Lexer) findInclude(name string) string {
+	dirs := append([]string{filepath.Dir(l.file)}, l.incPath...)
+	if filepath.IsAbs(name) {
+		dirs = []string{""}
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// scanString scans a string literal up to the closing quote and returns it as a String token with
+// the contents of the literal as its lexeme. Strings may not span lines. If the string isn't
+// terminated token.UnexpectedChar is returned.
+func (l *Lexer) scanString(tok *token.Token) *token.Token {
+	var strBuf bytes.Buffer
+	for {
+		err := l.readChar()
+		if err != nil {
+			return token.UnexpectedChar
+		}
+		if l.peek == '\n' {
+			l.ln++
+			return token.UnexpectedChar
+		}
+		if l.peek == '"' {
+			break
+		}
+		strBuf.WriteByte(l.peek)
+	}
+	tok.Tag = token.String
+	tok.Lex = strBuf.String()
+	return tok
+}
+
+// scanComments skips any comments at peek along with the whitespace after them, leaving the first
+// character after them in peek. Comments are // to the end of the line, /* */ and (* *), and { } in
+// the dialects other than the standard one, where braces are sets. It returns an io.EOF error if
+// EOF is encountered and an error token if a block comment isn't closed. Otherwise it returns nil.
+func (l *Lexer) scanComments() (*token.Token, error) {
+	for {
+		open, close := l.openComment()
+		if close == "" {
+			return nil, nil
+		}
+		// The error for an unterminated comment is where it was opened.
+		tok := token.New(l.ln, l.file)
+		if err := l.skipComment(open, close); err != nil {
+			if close == "\n" {
+				return nil, err
 			}
+			return l.newError(tok, "unterminated comment"), nil
+		}
+		if err := l.readCharAndWhitespace(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// openComment checks whether peek opens a comment. If it does the opening delimiter is consumed and
+// it returns the opening and closing delimiters. A line comment is closed by a newline. Otherwise
+// it returns empty strings.
+func (l *Lexer) openComment() (string, string) {
+	if l.peek == '{' && l.dialect != Standard {
+		return "{", "}"
+	}
+	for _, delims := range [][2]string{{"//", "\n"}, {"/*", "*/"}, {"(*", "*)"}} {
+		if l.matchAhead(delims[0]) {
+			return delims[0], delims[1]
+		}
+	}
+	return "", ""
+}
+
+// skipComment reads up to and including the closing delimiter of a comment. If comments nest, a
+// block comment opened inside it has to be closed first. It returns io.EOF if EOF is encountered.
+func (l *Lexer) skipComment(open string, close string) error {
+	depth := 1
+	for depth > 0 {
+		if err := l.readChar(); err != nil {
+			return err
+		}
+		if l.peek == '\n' {
+			l.ln++
+		}
+		if l.matchAhead(close) {
+			depth--
+		} else if l.nested && close != "\n" && l.matchAhead(open) {
+			depth++
 		}
 	}
 	return nil
 }
 
+// matchAhead returns true if peek and the characters after it in the input stream spell s. If they
+// do, the rest of s is consumed. Otherwise nothing is.
+func (l *Lexer) matchAhead(s string) bool {
+	if l.peek != s[0] {
+		return false
+	}
+	next, err := l.rd.Peek(len(s) - 1)
+	if err != nil || string(next) != s[1:] {
+		return false
+	}
+	l.rd.Discard(len(s) - 1)
+	return true
+}
+
 // loadKeywords loads reserved keywords into the reserved keyword table. Should be called on init.
 func (l *Lexer) loadKeywords() {
 	l.res["CONST"] = token.Const
@@ -243,6 +525,55 @@ func (l *Lexer) loadKeywords() {
 	l.res["WHILE"] = token.While
 	l.res["DO"] = token.Do
 	l.res["ODD"] = token.Odd
+	l.res["INC"] = token.Inc
+	l.res["DEC"] = token.Dec
+	l.res["WRITE"] = token.Write
+	l.res["WRITELN"] = token.Writeln
+	l.res["ASSERT"] = token.Assert
+	l.res["INTEGER"] = token.IntegerType
+	l.res["REAL"] = token.RealType
+	l.res["FLOAT"] = token.Float
+	l.res["TRUNC"] = token.Trunc
+	l.res["TYPE"] = token.Type
+	l.res["ORD"] = token.Ord
+	l.res["SUCC"] = token.Succ
+	l.res["PRED"] = token.Pred
+	l.res["FOR"] = token.For
+	l.res["TO"] = token.To
+	l.res["DOWNTO"] = token.Downto
+	l.res["CASE"] = token.Case
+	l.res["OF"] = token.Of
+	l.res["SET"] = token.Set
+	l.res["IN"] = token.In
+	l.res["MODULE"] = token.Module
+	l.res["IMPORT"] = token.Import
+	l.res["EXPORT"] = token.Export
+	l.res["INCLUDE"] = token.Include
+	l.res["STATIC"] = token.Static
+	l.res["OWN"] = token.Static
+	l.res["TRY"] = token.Try
+	l.res["EXCEPT"] = token.Except
+	l.res["RAISE"] = token.Raise
+	l.res["COROUTINE"] = token.Coroutine
+	l.res["YIELD"] = token.Yield
+	l.res["RESUME"] = token.Resume
+	l.res["COBEGIN"] = token.Cobegin
+	l.res["COEND"] = token.Coend
+	l.res["SEMAPHORE"] = token.Semaphore
+	l.res["WAIT"] = token.Wait
+	l.res["SIGNAL"] = token.Signal
+	l.res["HALT"] = token.Halt
+	l.res["FILE"] = token.File
+	l.res["OPEN"] = token.Open
+	l.res["CLOSE"] = token.Close
+	l.res["READ"] = token.Read
+	l.res["ASM"] = token.Asm
+	l.res["EXTERNAL"] = token.External
+	l.res["ARRAY"] = token.Array
+	l.res["STRING"] = token.StringType
+	l.res["CHAR"] = token.CharType
+	l.res["LENGTH"] = token.Length
+	l.res["ELSE"] = token.Else
 }
 
 // readChar reads a single character from the input stream and sets peek. It returns the error
@@ -250,6 +581,7 @@ func (l *Lexer) loadKeywords() {
 func (l *Lexer) readChar() error {
 	c, err := l.rd.ReadByte()
 	if err != nil {
+		l.eof = true
 		return err
 	}
 	l.peek = c
@@ -295,8 +627,12 @@ func (l *Lexer) readCharAndMatch(c byte) (bool, error) {
 	return true, nil
 }
 
-// unreadChar unreads the last character read from the input stream. It does not modify peek.
+// unreadChar unreads the last character read from the input stream. It does not modify peek. If
+// the last read hit the end of the input stream there is nothing to unread and it does nothing.
 func (l *Lexer) unreadChar() error {
+	if l.eof {
+		return nil
+	}
 	// Error should never be encountered.
 	return l.rd.UnreadByte()
 }
@@ -312,11 +648,14 @@ func isDigit(c byte) bool {
 	return c >= '0' && c <= '9'
 }
 
-// convertDigitToInt returns the integer version of the input byte if the input byte is a digit
-// (0-9). Otherwise it returns -1.
-func convertCharDigitToInt(c byte) int {
-	if isDigit(c) {
-		return int(c - '0')
+// isDigitInBase returns true if the input byte is a digit in base 2, 10 or 16. Hex digits may be
+// upper or lower case.
+func isDigitInBase(c byte, base int) bool {
+	switch base {
+	case 2:
+		return c == '0' || c == '1'
+	case 16:
+		return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
 	}
-	return -1
+	return isDigit(c)
 }
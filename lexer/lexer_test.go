@@ -1,6 +1,9 @@
 package lexer
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -40,6 +43,18 @@ var singleTokenTests = []singleTokenTestPair{
 	{"134", token.Token{Tag: token.Integer, Val: 134}},
 	{"134 ", token.Token{Tag: token.Integer, Val: 134}},
 	{" 00001 ", token.Token{Tag: token.Integer, Val: 1}},
+	{"3.25", token.Token{Tag: token.Real, Rval: 3.25}},
+	{"0.5 ", token.Token{Tag: token.Real, Rval: 0.5}},
+	{"12.", token.Token{Tag: token.Integer, Val: 12}},
+	{"0x1F", token.Token{Tag: token.Integer, Val: 31}},
+	{"0XfF ", token.Token{Tag: token.Integer, Val: 255}},
+	{"0b101", token.Token{Tag: token.Integer, Val: 5}},
+	{"0B1", token.Token{Tag: token.Integer, Val: 1}},
+	{"1_000_000", token.Token{Tag: token.Integer, Val: 1000000}},
+	{"0b1010_0101", token.Token{Tag: token.Integer, Val: 165}},
+	{"0x7FFF_FFFF", token.Token{Tag: token.Integer, Val: 2147483647}},
+	{"2147483647", token.Token{Tag: token.Integer, Val: 2147483647}},
+	{"1_000.25", token.Token{Tag: token.Real, Rval: 1000.25}},
 
 	{".", token.Token{Tag: token.Period}},
 	{",", token.Token{Tag: token.Comma}},
@@ -61,11 +76,19 @@ var singleTokenTests = []singleTokenTestPair{
 	{"(", token.Token{Tag: token.LeftParen}},
 	{")", token.Token{Tag: token.RightParen}},
 	{":=", token.Token{Tag: token.Assignment}},
-	{"::=", *token.UnexpectedChar},
-	{":", *token.UnexpectedChar},
-	{"://asdf", *token.UnexpectedChar},
+	{"::=", token.Token{Tag: token.Colon}},
+	{":", token.Token{Tag: token.Colon}},
+	{"://asdf", token.Token{Tag: token.Colon}},
+	{"..", token.Token{Tag: token.DotDot}},
+	{"\"\"", token.Token{Tag: token.String}},
+	{"\"a b:c\"", token.Token{Tag: token.String, Lex: "a b:c"}},
+	{"\"abc", *token.UnexpectedChar},
+	{"\"abc\ndef\"", *token.UnexpectedChar},
 	{"<=", token.Token{Tag: token.LessThanEqualTo}},
 	{">=", token.Token{Tag: token.GreaterThanEqualTo}},
+	{"+=", token.Token{Tag: token.PlusAssignment}},
+	{"-=", token.Token{Tag: token.MinusAssignment}},
+	{"*=", token.Token{Tag: token.TimesAssignment}},
 
 	{"Ident", token.Token{Tag: token.Identifier, Lex: "Ident"}},
 	{"Ident0123", token.Token{Tag: token.Identifier, Lex: "Ident0123"}},
@@ -79,6 +102,54 @@ var singleTokenTests = []singleTokenTestPair{
 	{"WHILE", token.Token{Tag: token.While}},
 	{"DO", token.Token{Tag: token.Do}},
 	{"ODD", token.Token{Tag: token.Odd}},
+	{"INC", token.Token{Tag: token.Inc}},
+	{"DEC", token.Token{Tag: token.Dec}},
+	{"WRITE", token.Token{Tag: token.Write}},
+	{"WRITELN", token.Token{Tag: token.Writeln}},
+	{"ASSERT", token.Token{Tag: token.Assert}},
+	{"INTEGER", token.Token{Tag: token.IntegerType}},
+	{"REAL", token.Token{Tag: token.RealType}},
+	{"FLOAT", token.Token{Tag: token.Float}},
+	{"TRUNC", token.Token{Tag: token.Trunc}},
+	{"TYPE", token.Token{Tag: token.Type}},
+	{"ORD", token.Token{Tag: token.Ord}},
+	{"SUCC", token.Token{Tag: token.Succ}},
+	{"PRED", token.Token{Tag: token.Pred}},
+	{"FOR", token.Token{Tag: token.For}},
+	{"TO", token.Token{Tag: token.To}},
+	{"DOWNTO", token.Token{Tag: token.Downto}},
+	{"CASE", token.Token{Tag: token.Case}},
+	{"OF", token.Token{Tag: token.Of}},
+	{"SET", token.Token{Tag: token.Set}},
+	{"IN", token.Token{Tag: token.In}},
+	{"MODULE", token.Token{Tag: token.Module}},
+	{"IMPORT", token.Token{Tag: token.Import}},
+	{"EXPORT", token.Token{Tag: token.Export}},
+	{"STATIC", token.Token{Tag: token.Static}},
+	{"OWN", token.Token{Tag: token.Static}},
+	{"TRY", token.Token{Tag: token.Try}},
+	{"EXCEPT", token.Token{Tag: token.Except}},
+	{"RAISE", token.Token{Tag: token.Raise}},
+	{"COROUTINE", token.Token{Tag: token.Coroutine}},
+	{"YIELD", token.Token{Tag: token.Yield}},
+	{"RESUME", token.Token{Tag: token.Resume}},
+	{"COBEGIN", token.Token{Tag: token.Cobegin}},
+	{"COEND", token.Token{Tag: token.Coend}},
+	{"SEMAPHORE", token.Token{Tag: token.Semaphore}},
+	{"WAIT", token.Token{Tag: token.Wait}},
+	{"SIGNAL", token.Token{Tag: token.Signal}},
+	{"HALT", token.Token{Tag: token.Halt}},
+	{"FILE", token.Token{Tag: token.File}},
+	{"OPEN", token.Token{Tag: token.Open}},
+	{"CLOSE", token.Token{Tag: token.Close}},
+	{"READ", token.Token{Tag: token.Read}},
+	{"ASM", token.Token{Tag: token.Asm}},
+	{"EXTERNAL", token.Token{Tag: token.External}},
+	{"ARRAY", token.Token{Tag: token.Array}},
+	{"STRING", token.Token{Tag: token.StringType}},
+	{"CHAR", token.Token{Tag: token.CharType}},
+	{"LENGTH", token.Token{Tag: token.Length}},
+	{"ELSE", token.Token{Tag: token.Else}},
 }
 
 var multiTokenTests = []multiTokenTestPair{
@@ -118,6 +189,22 @@ var multiTokenTests = []multiTokenTestPair{
 	{"x:=a/b;", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"}, token.Token{Tag: token.Assignment},
 		token.Token{Tag: token.Identifier, Lex: "a"}, token.Token{Tag: token.Divide},
 		token.Token{Tag: token.Identifier, Lex: "b"}, token.Token{Tag: token.Semicolon}, *token.EOF}},
+	{"x+=a-+b", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"}, token.Token{Tag: token.PlusAssignment},
+		token.Token{Tag: token.Identifier, Lex: "a"}, token.Token{Tag: token.Minus}, token.Token{Tag: token.Plus},
+		token.Token{Tag: token.Identifier, Lex: "b"}, *token.EOF}},
+	{"WRITE(x:3, \"y\")", []token.Token{token.Token{Tag: token.Write}, token.Token{Tag: token.LeftParen},
+		token.Token{Tag: token.Identifier, Lex: "x"}, token.Token{Tag: token.Colon},
+		token.Token{Tag: token.Integer, Val: 3}, token.Token{Tag: token.Comma},
+		token.Token{Tag: token.String, Lex: "y"}, token.Token{Tag: token.RightParen}, *token.EOF}},
+	{"x:=1.5.", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"}, token.Token{Tag: token.Assignment},
+		token.Token{Tag: token.Real, Rval: 1.5}, token.Token{Tag: token.Period}, *token.EOF}},
+	{"! 2.", []token.Token{token.Token{Tag: token.Exclamation}, token.Token{Tag: token.Integer, Val: 2},
+		token.Token{Tag: token.Period}, *token.EOF}},
+	{"0..31", []token.Token{token.Token{Tag: token.Integer, Val: 0}, token.Token{Tag: token.DotDot},
+		token.Token{Tag: token.Integer, Val: 31}, *token.EOF}},
+	{"{1}.", []token.Token{token.Token{Tag: token.LeftCurlyBrace}, token.Token{Tag: token.Integer, Val: 1},
+		token.Token{Tag: token.RightCurlyBrace}, token.Token{Tag: token.Period}, *token.EOF}},
+	{"a<", []token.Token{token.Token{Tag: token.Identifier, Lex: "a"}, token.Token{Tag: token.LessThan}, *token.EOF}},
 }
 
 func TestScan(t *testing.T) {
@@ -152,3 +239,253 @@ func TestScan(t *testing.T) {
 		}
 	}
 }
+
+type dialectTestPair struct {
+	dialect Dialect
+	test    string
+	expect  []token.Token
+}
+
+var dialectTests = []dialectTestPair{
+	{Standard, "BEGIN begin Begin", []token.Token{token.Token{Tag: token.Begin},
+		token.Token{Tag: token.Identifier, Lex: "begin"},
+		token.Token{Tag: token.Identifier, Lex: "Begin"}, *token.EOF}},
+	{Wirth, "BEGIN begin Begin", []token.Token{token.Token{Tag: token.Begin},
+		token.Token{Tag: token.Begin}, token.Token{Tag: token.Begin}, *token.EOF}},
+	{Wirth, "while x do Odd", []token.Token{token.Token{Tag: token.While},
+		token.Token{Tag: token.Identifier, Lex: "x"}, token.Token{Tag: token.Do},
+		token.Token{Tag: token.Odd}, *token.EOF}},
+	{Wirth, "Abc abc ABC", []token.Token{token.Token{Tag: token.Identifier, Lex: "Abc"},
+		token.Token{Tag: token.Identifier, Lex: "abc"},
+		token.Token{Tag: token.Identifier, Lex: "ABC"}, *token.EOF}},
+	{Wirth, "own \"Own\"", []token.Token{token.Token{Tag: token.Static},
+		token.Token{Tag: token.String, Lex: "Own"}, *token.EOF}},
+	{Caseless, "Abc abc ABC", []token.Token{token.Token{Tag: token.Identifier, Lex: "abc"},
+		token.Token{Tag: token.Identifier, Lex: "abc"},
+		token.Token{Tag: token.Identifier, Lex: "abc"}, *token.EOF}},
+	{Caseless, "Call x1Y", []token.Token{token.Token{Tag: token.Call},
+		token.Token{Tag: token.Identifier, Lex: "x1y"}, *token.EOF}},
+}
+
+func TestDialect(t *testing.T) {
+	for _, pair := range dialectTests {
+		l := NewFromString(pair.test)
+		l.SetDialect(pair.dialect)
+		out := []token.Token{}
+		for {
+			tok := l.Scan()
+			out = append(out, *tok)
+			if tok == token.EOF {
+				break
+			}
+		}
+		if !reflect.DeepEqual(out, pair.expect) {
+			t.Error(
+				"\nFor\n------\n"+pair.dialect.String()+": "+pair.test,
+				"\n------\nExpected\n------\n", pair.expect,
+				"\n------\nGot\n------\n", out,
+			)
+		}
+	}
+	for _, name := range []string{"standard", "wirth", "caseless"} {
+		if d, ok := ParseDialect(name); !ok || d.String() != name {
+			t.Error("ParseDialect(\"" + name + "\") doesn't round trip.")
+		}
+	}
+	if _, ok := ParseDialect("Wirth"); ok {
+		t.Error("ParseDialect(\"Wirth\") should fail.")
+	}
+}
+
+var numberTests = []multiTokenTestPair{
+	{"0x10.", []token.Token{token.Token{Tag: token.Integer, Val: 16}, token.Token{Tag: token.Period},
+		*token.EOF}},
+	{"0..0x1F", []token.Token{token.Token{Tag: token.Integer}, token.Token{Tag: token.DotDot},
+		token.Token{Tag: token.Integer, Val: 31}, *token.EOF}},
+	{"0x1G", []token.Token{token.Token{Tag: token.Integer, Val: 1},
+		token.Token{Tag: token.Identifier, Lex: "G"}, *token.EOF}},
+	{"2147483648", []token.Token{token.Token{Tag: token.Error}}},
+	{"x\n  4294967296", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"},
+		token.Token{Tag: token.Error, Ln: 1}}},
+	{"0x8000_0000", []token.Token{token.Token{Tag: token.Error}}},
+	{"0b1_0000_0000_0000_0000_0000_0000_0000_0000", []token.Token{token.Token{Tag: token.Error}}},
+	{"99999999999999999999", []token.Token{token.Token{Tag: token.Error}}},
+	{"1__0", []token.Token{token.Token{Tag: token.Error}}},
+	{"1_", []token.Token{token.Token{Tag: token.Error}}},
+	{"1_.5", []token.Token{token.Token{Tag: token.Error}}},
+	{"0x", []token.Token{token.Token{Tag: token.Error}}},
+	{"0x_1", []token.Token{token.Token{Tag: token.Error}}},
+	{"0b2", []token.Token{token.Token{Tag: token.Error}}},
+}
+
+func TestNumbers(t *testing.T) {
+	for _, pair := range numberTests {
+		l := NewFromString(pair.test)
+		out := []token.Token{}
+		for {
+			tok := l.Scan()
+			if tok.Tag == token.Error && tok != token.EOF {
+				// Only check where the error is.
+				out = append(out, token.Token{Tag: tok.Tag, Ln: tok.Ln, File: tok.File})
+				break
+			}
+			out = append(out, *tok)
+			if tok == token.EOF {
+				break
+			}
+		}
+		if !reflect.DeepEqual(out, pair.expect) {
+			t.Error(
+				"\nFor\n------\n"+pair.test,
+				"\n------\nExpected\n------\n", pair.expect,
+				"\n------\nGot\n------\n", out,
+			)
+		}
+	}
+}
+
+type commentTestPair struct {
+	dialect Dialect
+	nested  bool
+	test    string
+	expect  []token.Token
+}
+
+var commentTests = []commentTestPair{
+	{Standard, false, "(**)", []token.Token{*token.EOF}},
+	{Standard, false, "(* a *) x", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"},
+		*token.EOF}},
+	{Standard, false, "a (* b\n c *) d", []token.Token{token.Token{Tag: token.Identifier, Lex: "a"},
+		token.Token{Tag: token.Identifier, Lex: "d", Ln: 1}, *token.EOF}},
+	{Standard, false, "/* a\nb */ x", []token.Token{token.Token{Tag: token.Identifier, Lex: "x", Ln: 1},
+		*token.EOF}},
+	{Standard, false, "// a\nx", []token.Token{token.Token{Tag: token.Identifier, Lex: "x", Ln: 1},
+		*token.EOF}},
+	{Standard, false, "// a", []token.Token{*token.EOF}},
+	{Standard, false, "/* a */ // b\n(* c *) x", []token.Token{
+		token.Token{Tag: token.Identifier, Lex: "x", Ln: 1}, *token.EOF}},
+	{Standard, false, "(x*y)", []token.Token{token.Token{Tag: token.LeftParen},
+		token.Token{Tag: token.Identifier, Lex: "x"}, token.Token{Tag: token.Times},
+		token.Token{Tag: token.Identifier, Lex: "y"}, token.Token{Tag: token.RightParen}, *token.EOF}},
+	{Standard, false, "{1}", []token.Token{token.Token{Tag: token.LeftCurlyBrace},
+		token.Token{Tag: token.Integer, Val: 1}, token.Token{Tag: token.RightCurlyBrace}, *token.EOF}},
+	{Wirth, false, "{ a } x", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"}, *token.EOF}},
+	{Caseless, false, "{ a\n}", []token.Token{*token.EOF}},
+	{Wirth, false, "[1]", []token.Token{token.Token{Tag: token.LeftBracket},
+		token.Token{Tag: token.Integer, Val: 1}, token.Token{Tag: token.RightBracket}, *token.EOF}},
+	{Standard, false, "(* a (* b *) c *)", []token.Token{token.Token{Tag: token.Identifier, Lex: "c"},
+		token.Token{Tag: token.Times}, token.Token{Tag: token.RightParen}, *token.EOF}},
+	{Standard, true, "(* a (* b *) c *)", []token.Token{*token.EOF}},
+	{Standard, true, "/* a /* b */ */ x", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"},
+		*token.EOF}},
+	{Wirth, true, "{ a { b } } x", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"},
+		*token.EOF}},
+	{Standard, true, "(* a /* b *) x", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"},
+		*token.EOF}},
+	{Standard, false, "x\n(* a\n\n", []token.Token{token.Token{Tag: token.Identifier, Lex: "x"},
+		token.Token{Tag: token.Error, Ln: 1}}},
+	{Standard, false, "/* a *", []token.Token{token.Token{Tag: token.Error}}},
+	{Wirth, false, "\n{ a", []token.Token{token.Token{Tag: token.Error, Ln: 1}}},
+	{Standard, true, "(* (* *)", []token.Token{token.Token{Tag: token.Error}}},
+}
+
+func TestComments(t *testing.T) {
+	for _, pair := range commentTests {
+		l := NewFromString(pair.test)
+		l.SetDialect(pair.dialect)
+		l.SetNestedComments(pair.nested)
+		out := []token.Token{}
+		for {
+			tok := l.Scan()
+			if tok.Tag == token.Error && tok != token.EOF {
+				// Only check where the error is.
+				out = append(out, token.Token{Tag: tok.Tag, Ln: tok.Ln, File: tok.File})
+				break
+			}
+			out = append(out, *tok)
+			if tok == token.EOF {
+				break
+			}
+		}
+		if !reflect.DeepEqual(out, pair.expect) {
+			t.Error(
+				"\nFor\n------\n"+pair.test,
+				"\n------\nExpected\n------\n", pair.expect,
+				"\n------\nGot\n------\n", out,
+			)
+		}
+	}
+}
+
+// includeFiles are the files written to a temporary directory for includeTests.
+var includeFiles = map[string]string{
+	"a.sim":     "x\ny",
+	"b.sim":     "INCLUDE \"a.sim\" w",
+	"self.sim":  "INCLUDE \"self.sim\"",
+	"c.sim":     "INCLUDE \"d.sim\"",
+	"d.sim":     "\nINCLUDE \"c.sim\"",
+	"sub/e.sim": "INCLUDE \"a.sim\"",
+}
+
+var includeTests = []multiTokenTestPair{
+	{"INCLUDE \"a.sim\" z", []token.Token{token.Token{Tag: token.Identifier, Lex: "x", File: "a.sim"},
+		token.Token{Tag: token.Identifier, Lex: "y", Ln: 1, File: "a.sim"},
+		token.Token{Tag: token.Identifier, Lex: "z"}, *token.EOF}},
+	{"INCLUDE \"a.sim\" INCLUDE \"a.sim\" z", []token.Token{
+		token.Token{Tag: token.Identifier, Lex: "x", File: "a.sim"},
+		token.Token{Tag: token.Identifier, Lex: "y", Ln: 1, File: "a.sim"},
+		token.Token{Tag: token.Identifier, Lex: "z"}, *token.EOF}},
+	{"INCLUDE \"b.sim\" INCLUDE \"a.sim\"", []token.Token{
+		token.Token{Tag: token.Identifier, Lex: "x", File: "a.sim"},
+		token.Token{Tag: token.Identifier, Lex: "y", Ln: 1, File: "a.sim"},
+		token.Token{Tag: token.Identifier, Lex: "w", File: "b.sim"}, *token.EOF}},
+	{"INCLUDE \"sub/e.sim\"", []token.Token{token.Token{Tag: token.Identifier, Lex: "x", File: "a.sim"},
+		token.Token{Tag: token.Identifier, Lex: "y", Ln: 1, File: "a.sim"}, *token.EOF}},
+	{"INCLUDE \"self.sim\"", []token.Token{token.Token{Tag: token.Error, Ln: 0, File: "self.sim"}}},
+	{"INCLUDE \"c.sim\"", []token.Token{token.Token{Tag: token.Error, Ln: 1, File: "d.sim"}}},
+	{"\nINCLUDE \"none.sim\"", []token.Token{token.Token{Tag: token.Error, Ln: 1}}},
+	{"INCLUDE a", []token.Token{token.Token{Tag: token.Error, Ln: 0}}},
+}
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, contents := range includeFiles {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pair := range includeTests {
+		l := NewFromString(pair.test)
+		l.SetIncludePath([]string{dir})
+		out := []token.Token{}
+		for {
+			tok := l.Scan()
+			// Included files are named by their path, which depends on the temporary directory.
+			if rel, err := filepath.Rel(dir, tok.File); err == nil && tok.File != "" {
+				tok.File = rel
+			}
+			if tok == token.EOF {
+				out = append(out, *tok)
+				break
+			} else if tok.Tag == token.Error {
+				// Only check where the error is.
+				out = append(out, token.Token{Tag: tok.Tag, Ln: tok.Ln, File: tok.File})
+				break
+			}
+			out = append(out, *tok)
+		}
+		if !reflect.DeepEqual(out, pair.expect) {
+			t.Error(
+				"\nFor\n------\n"+pair.test,
+				"\n------\nExpected\n------\n", pair.expect,
+				"\n------\nGot\n------\n", out,
+			)
+		}
+	}
+}
@@ -3,9 +3,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/saicheems/simplelang/analyser"
 	"github.com/saicheems/simplelang/codegen"
@@ -13,23 +15,69 @@ import (
 	"github.com/saicheems/simplelang/parser"
 )
 
+var (
+	noAsserts = flag.Bool("noassert", false, "strip ASSERT statements from the output")
+	unsafe    = flag.Bool("unsafe", false, "omit runtime checks such as division by zero")
+	stackSize = flag.Int("stacksize", 0, "check procedure calls against a stack of this many bytes")
+	arith     = flag.String("arith", "", "integer arithmetic mode: wrap or checked")
+	modPath   = flag.String("modpath", ".", "list of directories searched for imported modules")
+	incPath   = flag.String("include", "", "list of directories searched for included files")
+	dialect   = flag.String("dialect", "standard", "source dialect: standard, wirth or caseless")
+	nested    = flag.Bool("nestcomments", false, "let block comments nest")
+)
+
 func main() {
-	if len(os.Args) != 2 {
+	flag.Parse()
+	if flag.NArg() != 1 {
 		fmt.Println("One argument is required: a path to a file to be compiled.")
 		return
 	}
-	f, err := os.Open(os.Args[1])
+	f, err := os.Open(flag.Arg(0))
 	if err != nil {
 		fmt.Println("Error opening file.")
 		return
 	}
+	opt := codegen.Options{NoAsserts: *noAsserts, Unsafe: *unsafe, StackSize: *stackSize}
+	switch *arith {
+	case "":
+		opt.Arithmetic = codegen.DefaultArithmetic
+	case "wrap":
+		opt.Arithmetic = codegen.WrappingArithmetic
+	case "checked":
+		opt.Arithmetic = codegen.CheckedArithmetic
+	default:
+		fmt.Println("Unknown arithmetic mode. Use wrap or checked.")
+		return
+	}
+	d, ok := lexer.ParseDialect(*dialect)
+	if !ok {
+		fmt.Println("Unknown dialect. Use standard, wirth or caseless.")
+		return
+	}
 	l := lexer.New(f)
+	l.SetDialect(d)
+	l.SetNestedComments(*nested)
+	l.SetIncludePath(filepath.SplitList(*incPath))
 	p := parser.New(l)
 	a := analyser.New(p)
+	a.SetModulePath(filepath.SplitList(*modPath))
 	c := codegen.New(a)
+	c.SetOptions(opt)
 	c.Generate()
 	code := c.String()
-	f, err = os.Create("out.s")
+	// A module is written to its own assembly file along with its interface file.
+	out := "out.s"
+	if name := c.ModuleName(); name != "" {
+		out = name + ".s"
+		f, err = os.Create(name + ".int")
+		if err != nil {
+			fmt.Println("Error creating interface file.")
+			return
+		}
+		c.WriteInterface(f)
+		f.Close()
+	}
+	f, err = os.Create(out)
 	if err != nil {
 		fmt.Println("Error creating output file.")
 	}
@@ -4,6 +4,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/saicheems/simplelang/ast"
 	"github.com/saicheems/simplelang/lexer"
@@ -14,6 +15,7 @@ import (
 type Parser struct {
 	lex  *lexer.Lexer
 	peek *token.Token // Next Token in the Token stream.
+	next *token.Token // Token after peek if it has been scanned already.
 	err  []error      // Set errors if we have a parse failure.
 }
 
@@ -27,10 +29,29 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// Dialect returns the dialect of the source being parsed.
+func (p *Parser) Dialect() lexer.Dialect {
+	return p.lex.Dialect()
+}
+
 // Parse returns the head node of the abstract syntax tree. If there is an error in the parse it
-// will return nil.
+// will return nil. A module starts with its name and may export names. Its block has no statement.
 func (p *Parser) Parse() *ast.Node {
-	block := p.parseBlock()
+	var name *token.Token
+	if p.accept(token.Module) {
+		name = p.peek
+		p.expect(token.Identifier)
+		p.expect(token.Semicolon)
+	}
+	imports := p.parseNameList(ast.NewImportNode(), token.Import)
+	exports := ast.NewExportNode()
+	var block *ast.Node
+	if name != nil {
+		exports = p.parseNameList(exports, token.Export)
+		block = p.parseModuleBlock()
+	} else {
+		block = p.parseBlock()
+	}
 	// Expect a period to finish the program.
 	p.expect(token.Period)
 	// Print the first error if there are any and return nil (I'm not confident in the quality
@@ -40,16 +61,47 @@ func (p *Parser) Parse() *ast.Node {
 		fmt.Println(p.err[0])
 		return nil
 	}
-	return ast.NewProgramNode(block)
+	return ast.NewProgramNode(name, block, imports, exports)
+}
+
+// parseNameList parses a list of names following the keyword with the given tag, ex. IMPORT a, b;
+// The names are appended to the node as terminal Nodes. The list is optional.
+func (p *Parser) parseNameList(node *ast.Node, t int) *ast.Node {
+	if !p.accept(t) {
+		return node
+	}
+	for {
+		iden := p.getTerminalNodeFromLookahead()
+		p.expect(token.Identifier)
+		node.AppendNode(iden)
+		if !p.accept(token.Comma) {
+			break
+		}
+	}
+	p.expect(token.Semicolon)
+	return node
 }
 
 // parseBlock parses blocks and returns a block Node.
 func (p *Parser) parseBlock() *ast.Node {
 	cons := p.parseConst()
-	vars := p.parseVar()
+	typs := p.parseTypeDecl()
+	vars := p.parseVar(ast.NewVarNode(), token.Var)
+	stat := p.parseVar(ast.NewStaticNode(), token.Static)
 	proc := p.parseProcedure()
 	stmt := p.parseStatement()
-	return ast.NewBlockNode(cons, vars, proc, stmt)
+	return ast.NewBlockNode(cons, typs, vars, stat, proc, stmt)
+}
+
+// parseModuleBlock parses the block of a module and returns a block Node. It has the declarations
+// of a block but no statement, so it gets an empty begin Node instead.
+func (p *Parser) parseModuleBlock() *ast.Node {
+	cons := p.parseConst()
+	typs := p.parseTypeDecl()
+	vars := p.parseVar(ast.NewVarNode(), token.Var)
+	stat := p.parseVar(ast.NewStaticNode(), token.Static)
+	proc := p.parseProcedure()
+	return ast.NewBlockNode(cons, typs, vars, stat, proc, ast.NewBeginNode())
 }
 
 // parseConst parses consts and returns a const Node.
@@ -63,7 +115,9 @@ func (p *Parser) parseConst() *ast.Node {
 		p.expect(token.Identifier)
 		p.expect(token.Equals)
 		inte := p.getTerminalNodeFromLookahead()
-		p.expect(token.Integer)
+		if !p.accept(token.Real) {
+			p.expect(token.Integer)
+		}
 		cons.AppendNode(ast.NewAssignmentNode(iden, inte))
 		if !p.accept(token.Comma) {
 			break
@@ -73,16 +127,72 @@ func (p *Parser) parseConst() *ast.Node {
 	return cons
 }
 
-// parseVar parses vars and returns a var Node.
-func (p *Parser) parseVar() *ast.Node {
-	vars := ast.NewVarNode()
-	if !p.accept(token.Var) {
+// parseTypeDecl parses type declarations and returns a type declaration Node. A type is declared as
+// an enumeration or as another name for any other type.
+func (p *Parser) parseTypeDecl() *ast.Node {
+	typs := ast.NewTypeDeclNode()
+	if !p.accept(token.Type) {
+		return typs
+	}
+	for {
+		iden := p.getTerminalNodeFromLookahead()
+		p.expect(token.Identifier)
+		p.expect(token.Equals)
+		var typ *ast.Node
+		if p.compareLookahead(token.LeftParen) {
+			typ = p.parseEnum()
+		} else {
+			typ = p.parseType()
+		}
+		p.expect(token.Semicolon)
+		typs.AppendNode(ast.NewAssignmentNode(iden, typ))
+		// Declarations continue for as long as there are names to declare. A statement can also
+		// start with a name, so the name has to be followed by an equals sign.
+		if !p.compareLookahead(token.Identifier) || p.lookaheadSecond().Tag != token.Equals {
+			break
+		}
+	}
+	return typs
+}
+
+// parseEnum parses the list of values of an enumeration and returns an enumeration Node.
+func (p *Parser) parseEnum() *ast.Node {
+	enum := ast.NewEnumNode()
+	p.expect(token.LeftParen)
+	for {
+		iden := p.getTerminalNodeFromLookahead()
+		p.expect(token.Identifier)
+		enum.AppendNode(iden)
+		if !p.accept(token.Comma) {
+			break
+		}
+	}
+	p.expect(token.RightParen)
+	return enum
+}
+
+// parseVar parses the vars following the keyword with the given tag (VAR or STATIC) and appends
+// them to the var or static Node, which it returns. A type applies to every identifier listed since
+// the previous type, so in VAR a, b: REAL, c; a and b are reals. The identifiers that have a type
+// get the type Node as their child.
+func (p *Parser) parseVar(vars *ast.Node, t int) *ast.Node {
+	if !p.accept(t) {
 		return vars
 	}
+	untyped := 0 // Index of the first identifier without a type.
 	for {
 		iden := p.getTerminalNodeFromLookahead()
 		p.expect(token.Identifier)
 		vars.AppendNode(iden)
+		if p.accept(token.Colon) {
+			typ := p.parseType()
+			for _, iden := range vars.Children[untyped:] {
+				if iden != nil {
+					iden.AppendNode(typ)
+				}
+			}
+			untyped = len(vars.Children)
+		}
 		if !p.accept(token.Comma) {
 			break
 		}
@@ -91,38 +201,120 @@ func (p *Parser) parseVar() *ast.Node {
 	return vars
 }
 
-// parseProcedure parses procedures and returns a procedure Node.
+// parseType parses a type and returns a type Node. Declared types are named by an identifier.
+func (p *Parser) parseType() *ast.Node {
+	typ := ast.NewTypeNode(p.peek)
+	if p.accept(token.Set) {
+		p.expect(token.Of)
+		low := p.getTerminalNodeFromLookahead()
+		p.expect(token.Integer)
+		p.expect(token.DotDot)
+		high := p.getTerminalNodeFromLookahead()
+		p.expect(token.Integer)
+		typ.AppendNode(low, high)
+	} else if p.accept(token.Array) {
+		length := p.getTerminalNodeFromLookahead()
+		p.expect(token.Integer)
+		p.expect(token.Of)
+		typ.AppendNode(length, p.parseType())
+	} else if !p.accept(token.RealType) && !p.accept(token.Semaphore) && !p.accept(token.File) &&
+		!p.accept(token.StringType) && !p.accept(token.CharType) && !p.accept(token.Identifier) {
+		p.expect(token.IntegerType)
+	}
+	return typ
+}
+
+// parseProcedure parses procedures, coroutines and external procedures and returns a procedure
+// Node.
 func (p *Parser) parseProcedure() *ast.Node {
 	proc := ast.NewProcedureParentNode()
-	if !p.accept(token.Procedure) {
-		return proc
-	}
-	for {
+	for p.compareLookahead(token.Procedure, token.Coroutine, token.External) {
+		if p.compareLookahead(token.External) {
+			proc.AppendNode(p.parseExternal())
+			continue
+		}
+		coroutine := p.accept(token.Coroutine)
+		if !coroutine {
+			p.move()
+		}
 		iden := p.getTerminalNodeFromLookahead()
 		p.expect(token.Identifier)
 		p.expect(token.Semicolon)
 		bloc := p.parseBlock()
 		p.expect(token.Semicolon)
-		proc.AppendNode(ast.NewProcedureNode(iden, bloc))
-		if !p.accept(token.Procedure) {
-			break
+		if coroutine {
+			proc.AppendNode(ast.NewCoroutineNode(iden, bloc))
+		} else {
+			proc.AppendNode(ast.NewProcedureNode(iden, bloc))
 		}
 	}
 	return proc
 }
 
+// parseExternal parses an external procedure declaration and returns an external Node. The names of
+// the arguments are only there to document them.
+func (p *Parser) parseExternal() *ast.Node {
+	p.expect(token.External)
+	p.expect(token.Procedure)
+	external := ast.NewExternalNode(p.getTerminalNodeFromLookahead())
+	p.expect(token.Identifier)
+	if p.accept(token.LeftParen) {
+		for {
+			external.AppendNode(p.getTerminalNodeFromLookahead())
+			p.expect(token.Identifier)
+			if !p.accept(token.Comma) {
+				break
+			}
+		}
+		p.expect(token.RightParen)
+	}
+	p.expect(token.Semicolon)
+	return external
+}
+
+// parseArguments parses the arguments of a call of an external procedure, if there are any, and
+// adds them to the call Node.
+func (p *Parser) parseArguments(call *ast.Node) {
+	if !p.accept(token.LeftParen) || p.accept(token.RightParen) {
+		return
+	}
+	for {
+		call.AppendNode(p.parseExpression())
+		if !p.accept(token.Comma) {
+			break
+		}
+	}
+	p.expect(token.RightParen)
+}
+
 // parseStatement parses all types of statement and returns the particular statement Node. Returns
 // nil if no statement can be parsed.
 func (p *Parser) parseStatement() *ast.Node {
 	iden := p.getTerminalNodeFromLookahead()
 	if p.accept(token.Identifier) {
+		iden = p.parseIndices(iden)
+		tok := p.peek
+		if p.accept(token.PlusAssignment) {
+			expr := p.parseExpression()
+			return ast.NewCompoundAssignmentNode(token.Plus, tok, iden, expr)
+		} else if p.accept(token.MinusAssignment) {
+			expr := p.parseExpression()
+			return ast.NewCompoundAssignmentNode(token.Minus, tok, iden, expr)
+		} else if p.accept(token.TimesAssignment) {
+			expr := p.parseExpression()
+			return ast.NewCompoundAssignmentNode(token.Times, tok, iden, expr)
+		}
 		p.expect(token.Assignment)
 		expr := p.parseExpression()
 		return ast.NewAssignmentNode(iden, expr)
+	} else if p.compareLookahead(token.Inc, token.Dec) {
+		return p.parseIncDec()
 	} else if p.accept(token.Call) {
 		iden := p.getTerminalNodeFromLookahead()
 		p.expect(token.Identifier)
-		return ast.NewCallNode(iden)
+		call := ast.NewCallNode(iden)
+		p.parseArguments(call)
+		return call
 	} else if p.accept(token.Begin) {
 		begin := ast.NewBeginNode()
 		for {
@@ -130,8 +322,7 @@ func (p *Parser) parseStatement() *ast.Node {
 			begin.AppendNode(stmt)
 			p.expect(token.Semicolon)
 			// If the next token can't begin a statement, stop looking for them.
-			if !p.compareLookahead(token.Identifier, token.Call, token.Begin,
-				token.If, token.While, token.Exclamation) {
+			if !p.startsStatement() {
 				break
 			}
 		}
@@ -150,6 +341,50 @@ func (p *Parser) parseStatement() *ast.Node {
 	} else if p.accept(token.Exclamation) {
 		expr := p.parseExpression()
 		return ast.NewPrintNode(expr)
+	} else if p.compareLookahead(token.For) {
+		return p.parseFor()
+	} else if p.compareLookahead(token.Case) {
+		return p.parseCase()
+	} else if p.compareLookahead(token.Write, token.Writeln) {
+		return p.parseWrite()
+	} else if p.compareLookahead(token.Assert) {
+		return p.parseAssert()
+	} else if p.compareLookahead(token.Try) {
+		return p.parseTry()
+	} else if p.compareLookahead(token.Raise) {
+		tok := p.peek
+		p.move()
+		return ast.NewRaiseNode(tok, p.parseExpression())
+	} else if p.compareLookahead(token.Yield) {
+		tok := p.peek
+		p.move()
+		return ast.NewYieldNode(tok, p.parseExpression())
+	} else if p.compareLookahead(token.Cobegin) {
+		return p.parseCobegin()
+	} else if p.compareLookahead(token.Wait, token.Signal) {
+		tok := p.peek
+		p.move()
+		p.expect(token.LeftParen)
+		iden := p.getTerminalNodeFromLookahead()
+		p.expect(token.Identifier)
+		p.expect(token.RightParen)
+		if tok.Tag == token.Wait {
+			return ast.NewWaitNode(tok, iden)
+		}
+		return ast.NewSignalNode(tok, iden)
+	} else if p.compareLookahead(token.Open, token.Close, token.Read) {
+		return p.parseFile()
+	} else if p.compareLookahead(token.Asm) {
+		return p.parseAsm()
+	} else if p.compareLookahead(token.Halt) {
+		tok := p.peek
+		p.move()
+		if !p.accept(token.LeftParen) {
+			return ast.NewHaltNode(tok, nil)
+		}
+		code := p.parseExpression()
+		p.expect(token.RightParen)
+		return ast.NewHaltNode(tok, code)
 	} else {
 		// If this function is called we expect to parse a statement.
 		p.appendError()
@@ -157,6 +392,265 @@ func (p *Parser) parseStatement() *ast.Node {
 	}
 }
 
+// parseFor parses for statements and returns a for Node. The control variable is assigned like in
+// an assignment statement and counts up to the limit with TO or down to it with DOWNTO.
+func (p *Parser) parseFor() *ast.Node {
+	tok := p.peek
+	p.expect(token.For)
+	iden := p.getTerminalNodeFromLookahead()
+	p.expect(token.Identifier)
+	p.expect(token.Assignment)
+	init := ast.NewAssignmentNode(iden, p.parseExpression())
+	op := token.To
+	if !p.accept(token.To) {
+		p.expect(token.Downto)
+		op = token.Downto
+	}
+	limit := p.parseExpression()
+	p.expect(token.Do)
+	stmt := p.parseStatement()
+	return ast.NewForNode(tok, op, init, limit, stmt)
+}
+
+// parseCase parses case statements and returns a case Node. Every arm has a list of labels and a
+// statement followed by a semicolon, and the arms end with END.
+func (p *Parser) parseCase() *ast.Node {
+	tok := p.peek
+	p.expect(token.Case)
+	expr := p.parseExpression()
+	p.expect(token.Of)
+	cas := ast.NewCaseNode(tok, expr)
+	for {
+		var labels []*ast.Node
+		for {
+			labels = append(labels, p.parseExpression())
+			if !p.accept(token.Comma) {
+				break
+			}
+		}
+		p.expect(token.Colon)
+		arm := ast.NewArmNode(p.parseStatement())
+		arm.AppendNode(labels...)
+		p.expect(token.Semicolon)
+		cas.AppendNode(arm)
+		if p.compareLookahead(token.End) || p.peek == token.EOF {
+			break
+		}
+	}
+	p.expect(token.End)
+	return cas
+}
+
+// startsStatement returns a bool representing whether or not the peek Token can begin a statement.
+func (p *Parser) startsStatement() bool {
+	return p.compareLookahead(token.Identifier, token.Call, token.Begin, token.If, token.While,
+		token.For, token.Case, token.Exclamation, token.Inc, token.Dec, token.Write, token.Writeln,
+		token.Assert, token.Try, token.Raise, token.Yield, token.Cobegin, token.Wait, token.Signal,
+		token.Halt, token.Open, token.Close, token.Read, token.Asm)
+}
+
+// parseTry parses try statements and returns a try Node. Like a BEGIN, every statement is followed
+// by a semicolon, including the statement of each handler.
+func (p *Parser) parseTry() *ast.Node {
+	tok := p.peek
+	p.expect(token.Try)
+	body := ast.NewBeginNode()
+	for {
+		body.AppendNode(p.parseStatement())
+		p.expect(token.Semicolon)
+		if !p.startsStatement() {
+			break
+		}
+	}
+	try := ast.NewTryNode(tok, body)
+	p.expect(token.Except)
+	for {
+		code := p.parseExpression()
+		p.expect(token.Colon)
+		stmt := p.parseStatement()
+		p.expect(token.Semicolon)
+		try.AppendNode(ast.NewHandlerNode(code, stmt))
+		if p.compareLookahead(token.End) || p.peek == token.EOF {
+			break
+		}
+	}
+	p.expect(token.End)
+	return try
+}
+
+// parseCobegin parses a cobegin statement and returns a cobegin Node. The processes are procedure
+// calls separated by semicolons, and a semicolon may follow the last one.
+func (p *Parser) parseCobegin() *ast.Node {
+	cobegin := ast.NewCobeginNode(p.peek)
+	p.expect(token.Cobegin)
+	for {
+		p.expect(token.Call)
+		iden := p.getTerminalNodeFromLookahead()
+		p.expect(token.Identifier)
+		cobegin.AppendNode(ast.NewCallNode(iden))
+		if !p.accept(token.Semicolon) || !p.compareLookahead(token.Call) {
+			break
+		}
+	}
+	p.expect(token.Coend)
+	return cobegin
+}
+
+// parseIncDec parses INC and DEC statements and returns a compound assignment Node. The amount
+// defaults to 1 if it is omitted.
+func (p *Parser) parseIncDec() *ast.Node {
+	tok := p.peek
+	op := token.Plus
+	if !p.accept(token.Inc) {
+		p.expect(token.Dec)
+		op = token.Minus
+	}
+	p.expect(token.LeftParen)
+	iden := p.getTerminalNodeFromLookahead()
+	p.expect(token.Identifier)
+	iden = p.parseIndices(iden)
+	var expr *ast.Node
+	if p.accept(token.Comma) {
+		expr = p.parseExpression()
+	} else {
+		one := &token.Token{Tag: token.Integer, Val: 1, Ln: p.peek.Ln, File: p.peek.File}
+		expr = ast.NewTerminalNode(one)
+	}
+	p.expect(token.RightParen)
+	return ast.NewCompoundAssignmentNode(op, tok, iden, expr)
+}
+
+// parseWrite parses WRITE and WRITELN statements and returns a write Node. WRITELN may be given
+// without any arguments to print just a newline.
+func (p *Parser) parseWrite() *ast.Node {
+	write := ast.NewWriteNode(p.peek.Tag)
+	if p.accept(token.Writeln) && !p.compareLookahead(token.LeftParen) {
+		return write
+	}
+	p.accept(token.Write)
+	p.expect(token.LeftParen)
+	for {
+		// A string on its own is a factor like any other.
+		item := p.parseExpression()
+		var width *ast.Node
+		if p.accept(token.Colon) {
+			width = p.parseExpression()
+		}
+		write.AppendNode(ast.NewFormatNode(item, width))
+		if !p.accept(token.Comma) {
+			break
+		}
+	}
+	p.expect(token.RightParen)
+	return write
+}
+
+// parseFile parses OPEN, CLOSE and READ statements and returns an open, close or read Node. Each
+// of them names the file first.
+func (p *Parser) parseFile() *ast.Node {
+	tok := p.peek
+	p.move()
+	p.expect(token.LeftParen)
+	iden := p.getTerminalNodeFromLookahead()
+	p.expect(token.Identifier)
+	var node *ast.Node
+	switch tok.Tag {
+	case token.Open:
+		p.expect(token.Comma)
+		name := ast.NewTerminalNode(p.peek)
+		p.expect(token.String)
+		var mode *ast.Node
+		if p.accept(token.Comma) {
+			mode = p.parseExpression()
+		}
+		node = ast.NewOpenNode(tok, iden, name, mode)
+	case token.Close:
+		node = ast.NewCloseNode(tok, iden)
+	case token.Read:
+		node = ast.NewReadNode(tok, iden)
+		for p.accept(token.Comma) {
+			node.AppendNode(p.getTerminalNodeFromLookahead())
+			p.expect(token.Identifier)
+		}
+	}
+	p.expect(token.RightParen)
+	return node
+}
+
+// parseAsm parses asm statements and returns an asm Node. Each string is a line of assembly, which
+// may refer to a single variable as {name}.
+func (p *Parser) parseAsm() *ast.Node {
+	asm := ast.NewAsmNode(p.peek)
+	p.expect(token.Asm)
+	for {
+		line := ast.NewTerminalNode(p.peek)
+		if p.compareLookahead(token.String) && !p.parseAsmReference(line) {
+			p.appendError()
+			return asm
+		}
+		if !p.expect(token.String) {
+			return asm
+		}
+		asm.AppendNode(line)
+		if !p.compareLookahead(token.String) {
+			break
+		}
+	}
+	p.expect(token.End)
+	return asm
+}
+
+// parseAsmReference looks for a reference to a variable in a line of assembly and adds a terminal
+// Node naming it to the line. It returns false if the braces of the line are malformed or the line
+// refers to more than one variable.
+func (p *Parser) parseAsmReference(line *ast.Node) bool {
+	text := line.Tok.Lex
+	open := strings.IndexByte(text, '{')
+	close := strings.IndexByte(text, '}')
+	if open < 0 || close < 0 {
+		return open == close
+	}
+	name := text[open+1 : close]
+	if !isName(name) || strings.ContainsAny(text[close+1:], "{}") {
+		return false
+	}
+	if p.lex.Dialect() == lexer.Caseless {
+		name = strings.ToLower(name)
+	}
+	tok := &token.Token{Tag: token.Identifier, Lex: name, Ln: line.Tok.Ln, File: line.Tok.File}
+	line.AppendNode(ast.NewTerminalNode(tok))
+	return true
+}
+
+// isName returns a bool representing whether or not a string is spelled like an identifier.
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAssert parses assert statements and returns an assert Node.
+func (p *Parser) parseAssert() *ast.Node {
+	tok := p.peek
+	p.expect(token.Assert)
+	p.expect(token.LeftParen)
+	cond := p.parseCondition()
+	var code *ast.Node
+	if p.accept(token.Comma) {
+		code = p.parseExpression()
+	}
+	p.expect(token.RightParen)
+	return ast.NewAssertNode(tok, cond, code)
+}
+
 // parseCondition parses conditions and returns a condition Node.
 func (p *Parser) parseCondition() *ast.Node {
 	if p.accept(token.Odd) {
@@ -165,7 +659,9 @@ func (p *Parser) parseCondition() *ast.Node {
 	} else {
 		left := p.parseExpression()
 		equalOp := token.GreaterThanEqualTo
-		if p.accept(token.Equals) {
+		if p.accept(token.In) {
+			equalOp = token.In
+		} else if p.accept(token.Equals) {
 			equalOp = token.Equals
 		} else if p.accept(token.NotEquals) {
 			equalOp = token.NotEquals
@@ -188,9 +684,10 @@ func (p *Parser) parseExpression() *ast.Node {
 	op := int(token.Plus)
 	var term *ast.Node
 
+	tok := p.peek
 	if p.accept(token.Minus) {
 		op = token.Minus
-		term = ast.NewMathNode(op,
+		term = ast.NewMathNode(op, tok,
 			ast.NewTerminalNode(&token.Token{Tag: token.Integer, Val: 0}),
 			p.parseTerm())
 	} else {
@@ -198,6 +695,7 @@ func (p *Parser) parseExpression() *ast.Node {
 		term = p.parseTerm()
 	}
 	for {
+		tok := p.peek
 		if p.accept(token.Plus) {
 			op = token.Plus
 		} else if p.accept(token.Minus) {
@@ -206,7 +704,7 @@ func (p *Parser) parseExpression() *ast.Node {
 			break
 		}
 		second := p.parseTerm()
-		term = ast.NewMathNode(op, term, second)
+		term = ast.NewMathNode(op, tok, term, second)
 	}
 	return term
 }
@@ -216,6 +714,7 @@ func (p *Parser) parseTerm() *ast.Node {
 	op := int(token.Times)
 	fact := p.parseFactor()
 	for {
+		tok := p.peek
 		if p.accept(token.Times) {
 			op = token.Times
 		} else if p.accept(token.Divide) {
@@ -224,7 +723,7 @@ func (p *Parser) parseTerm() *ast.Node {
 			break
 		}
 		second := p.parseFactor()
-		fact = ast.NewMathNode(op, fact, second)
+		fact = ast.NewMathNode(op, tok, fact, second)
 	}
 	return fact
 }
@@ -232,22 +731,112 @@ func (p *Parser) parseTerm() *ast.Node {
 // parseFactor parses factors and returns either a math Node or a terminal Node.
 func (p *Parser) parseFactor() *ast.Node {
 	iden := p.getTerminalNodeFromLookahead()
-	if p.accept(token.Identifier) || p.accept(token.Integer) {
+	if p.accept(token.Identifier) {
+		if !p.compareLookahead(token.LeftParen) {
+			return p.parseIndices(iden)
+		}
+		// Only an external procedure can be called in an expression. Like any other expression
+		// the call has a token for errors.
+		call := ast.NewCallNode(iden)
+		call.Tok = iden.Tok
+		p.parseArguments(call)
+		return call
+	} else if p.accept(token.Integer) || p.accept(token.Real) {
 		return iden
+	} else if p.compareLookahead(token.String) {
+		str := ast.NewTerminalNode(p.peek)
+		p.move()
+		return str
+	} else if p.compareLookahead(token.Float, token.Trunc, token.Ord, token.Succ, token.Pred,
+		token.Length) {
+		tok := p.peek
+		p.move()
+		p.expect(token.LeftParen)
+		expr := p.parseExpression()
+		p.expect(token.RightParen)
+		return ast.NewFunctionNode(tok, expr)
 	} else if p.accept(token.LeftParen) {
 		expr := p.parseExpression()
 		p.expect(token.RightParen)
 		return expr
+	} else if p.compareLookahead(token.LeftCurlyBrace, token.LeftBracket) {
+		return p.parseSet()
+	} else if p.compareLookahead(token.Resume) {
+		tok := p.peek
+		p.move()
+		iden := p.getTerminalNodeFromLookahead()
+		p.expect(token.Identifier)
+		return ast.NewResumeNode(tok, iden)
+	} else if p.compareLookahead(token.If) {
+		return p.parseConditional()
 	} else {
+		// If this function is called we expect to parse a factor.
+		p.appendError()
 		return nil
 	}
 }
 
-// getTerminalNodeFromLookahead returns a Node containing the peek token if it is of type Integer or
-// Identifier.
+// parseConditional parses a conditional expression and returns a conditional Node. Like the right
+// hand side of an operator, the ELSE arm is a whole expression, so IF c THEN 1 ELSE 2 + 3 adds 3 to
+// the ELSE arm only.
+func (p *Parser) parseConditional() *ast.Node {
+	tok := p.peek
+	p.expect(token.If)
+	cond := p.parseCondition()
+	p.expect(token.Then)
+	then := p.parseExpression()
+	p.expect(token.Else)
+	els := p.parseExpression()
+	return ast.NewConditionalNode(tok, cond, then, els)
+}
+
+// parseIndices parses the indices following the name of a variable, if any, and returns the Node of
+// the indexed element. Each index wraps the Node before it, so m[i][j] indexes m[i] with j.
+func (p *Parser) parseIndices(iden *ast.Node) *ast.Node {
+	for p.compareLookahead(token.LeftBracket) {
+		tok := p.peek
+		p.move()
+		index := p.parseExpression()
+		p.expect(token.RightBracket)
+		iden = ast.NewIndexNode(tok, iden, index)
+	}
+	return iden
+}
+
+// parseSet parses a set literal and returns a set Node. Each element is either an expression or a
+// range of expressions. The set may be empty. It's enclosed in braces or, since braces are comments
+// in some dialects, in brackets.
+func (p *Parser) parseSet() *ast.Node {
+	set := ast.NewSetNode(p.peek)
+	closing := token.RightCurlyBrace
+	if p.accept(token.LeftBracket) {
+		closing = token.RightBracket
+	} else {
+		p.expect(token.LeftCurlyBrace)
+	}
+	if p.accept(closing) {
+		return set
+	}
+	for {
+		elem := p.parseExpression()
+		tok := p.peek
+		if p.accept(token.DotDot) {
+			elem = ast.NewRangeNode(tok, elem, p.parseExpression())
+		}
+		set.AppendNode(elem)
+		if !p.accept(token.Comma) {
+			break
+		}
+	}
+	p.expect(closing)
+	return set
+}
+
+// getTerminalNodeFromLookahead returns a Node containing the peek token if it is of type Integer,
+// Real or Identifier.
 func (p *Parser) getTerminalNodeFromLookahead() *ast.Node {
 	// Only return a node if the peekahead token is an actual terminal.
-	if p.peek.Tag == token.Identifier || p.peek.Tag == token.Integer {
+	if p.peek.Tag == token.Identifier || p.peek.Tag == token.Integer || p.peek.Tag == token.Real {
 		return ast.NewTerminalNode(p.peek)
 	}
 	return nil
@@ -255,8 +844,30 @@ func (p *Parser) getTerminalNodeFromLookahead() *ast.Node {
 
 // move Moves the token stream forward by one token and sets the peek token.
 func (p *Parser) move() {
+	if p.next != nil {
+		p.peek = p.next
+		p.next = nil
+		return
+	}
+	p.peek = p.scan()
+}
+
+// lookaheadSecond returns the Token after the peek Token without moving past either of them.
+func (p *Parser) lookaheadSecond() *token.Token {
+	if p.next == nil {
+		p.next = p.scan()
+	}
+	return p.next
+}
+
+// scan returns the next Token from the lexer. Lexical errors that describe themselves (such as a
+// failed INCLUDE) are added to the err list straight away.
+func (p *Parser) scan() *token.Token {
 	tok := p.lex.Scan()
-	p.peek = tok
+	if tok.Tag == token.Error && tok != token.EOF && tok != token.UnexpectedChar {
+		p.err = append(p.err, tok.Err)
+	}
+	return tok
 }
 
 // compareLookahead takes in any number of tags and returns a bool representing whether or not any
@@ -292,7 +903,8 @@ func (p *Parser) expect(t int) bool {
 	return acc
 }
 
-// appendError adds a new "syntax" error to the err list.
+// appendError adds a new "syntax" error to the err list. The dialect decides what the keywords are,
+// so it's part of the error.
 func (p *Parser) appendError() {
-	p.err = append(p.err, fmt.Errorf("Syntax error near line %d.", p.peek.Ln))
+	p.err = append(p.err, fmt.Errorf("Syntax error %s (%s dialect).", p.peek.Pos(), p.Dialect()))
 }
@@ -99,6 +99,167 @@ var tests = []testPair{
 		"\t\tc:=a+b;\n" +
 		"\tEND;\n" +
 		"CALL sum.\n", true},
+	{"VAR x; BEGIN x += 3; x -= 2; x *= x + 1; END.", true},
+	{"VAR x; BEGIN INC(x); DEC(x); INC(x, 2); DEC(x, x * 2); END.", true},
+	{"VAR x; BEGIN INC x; END.", false},
+	{"VAR x; BEGIN INC(3); END.", false},
+	{"VAR x; BEGIN x + 3; END.", false},
+	{"VAR x; BEGIN x := ; END.", false},
+	{"VAR x; BEGIN WRITE(x); WRITELN(x, \"a\", 3); WRITELN; END.", true},
+	{"VAR x; BEGIN WRITELN(x:3, \"a\":x + 1); END.", true},
+	{"VAR x; BEGIN WRITE; END.", false},
+	{"VAR x; BEGIN WRITE(); END.", false},
+	{"VAR x; BEGIN WRITE(x:); END.", false},
+	{"VAR x; BEGIN WRITE(x:\"a\"); END.", true},
+	{"VAR x; BEGIN x := \"a\"; END.", true},
+	{"VAR x; BEGIN ASSERT(x = 3); ASSERT(ODD x, 2); END.", true},
+	{"VAR x; BEGIN ASSERT(x); END.", false},
+	{"VAR x; BEGIN ASSERT x = 3; END.", false},
+	{"VAR x; BEGIN ASSERT(x = 3,); END.", false},
+	{"VAR x; BEGIN x := x / 0; END.", true},
+	{"CONST pi = 3.14; VAR r, s: REAL, i, j: INTEGER, k; BEGIN r := pi * 2.0; END.", true},
+	{"VAR r: REAL; BEGIN r := FLOAT(3) / 2; ! TRUNC(r + 0.5); END.", true},
+	{"VAR r: REAL; BEGIN r := FLOAT 3; END.", false},
+	{"VAR r:; BEGIN r := 3; END.", false},
+	{"VAR r: x; BEGIN r := 3; END.", true},
+	{"VAR r: REAL: REAL; BEGIN r := 3; END.", false},
+	{"CONST c = -1.5; VAR r; BEGIN r := 3; END.", false},
+	{"TYPE Colour = (Red, Green, Blue); VAR c: Colour; BEGIN c := SUCC(Red); END.", true},
+	{"TYPE A = (X); B = (Y, Z); VAR a: A; BEGIN ! ORD(PRED(Z)); END.", true},
+	{"CONST n = 3; TYPE A = (X, Y); VAR a: A; BEGIN a := X; END.", true},
+	{"TYPE A = (); VAR a: A; BEGIN a := 3; END.", false},
+	{"TYPE A = (X,); VAR a: A; BEGIN a := X; END.", false},
+	{"TYPE A = X, Y; VAR a: A; BEGIN a := X; END.", false},
+	{"TYPE A = (X, Y) VAR a: A; BEGIN a := X; END.", false},
+	{"TYPE; VAR a; BEGIN a := 3; END.", false},
+	{"VAR a; TYPE A = (X); BEGIN a := 3; END.", false},
+	{"TYPE C = (R, G); VAR g: C; PROCEDURE p; TYPE D = (X, Y); g := G; CALL p.", true},
+	{"TYPE C = (R, G); BEGIN ! ORD(G); END.", true},
+	{"TYPE C = (R, G); D = (X, Y); g := G.", true},
+	{"TYPE C = (R, G); g G.", false},
+	{"VAR i, n; BEGIN FOR i := 1 TO 10 DO n := n + i; FOR i := n DOWNTO 0 DO ! i; END.", true},
+	{"TYPE C = (R, G); VAR c: C; FOR c := R TO G DO BEGIN ! ORD(c); END.", true},
+	{"VAR i; FOR i = 1 TO 10 DO ! i.", false},
+	{"VAR i; FOR i := 1 10 DO ! i.", false},
+	{"VAR i; FOR i := 1 TO 10 ! i.", false},
+	{"VAR i; FOR 1 TO 10 DO ! i.", false},
+	{"TYPE C = (R, G); VAR c: C; CASE c OF R: ! 1; G: BEGIN ! 2; END; END.", true},
+	{"VAR i; BEGIN CASE i + 1 OF 1, 2, 3: i := 0; 4: ! i; END; ! i; END.", true},
+	{"VAR i; CASE i OF END.", false},
+	{"VAR i; CASE i 1: ! i; END.", false},
+	{"VAR i; CASE i OF 1 ! i; END.", false},
+	{"VAR i; CASE i OF 1, : ! i; END.", false},
+	{"VAR i; CASE i OF 1: ! i END.", false},
+	{"VAR i; CASE i OF 1: ! i;.", false},
+	{"VAR s, t: SET OF 0..31; BEGIN s := {1, 3, 5..7}; t := s + {} - {2} * s; END.", true},
+	{"VAR s: SET OF 0..31, x; BEGIN IF x IN s THEN x := 1; IF x + 1 IN {x} THEN x := 2; END.", true},
+	{"VAR s: SET OF 0..31; BEGIN s := {1,}; END.", false},
+	{"VAR s: SET OF 0..31; BEGIN s := {1..}; END.", false},
+	{"VAR s: SET OF 0..31; BEGIN s := {1; END.", false},
+	{"VAR s: SET 0..31; BEGIN s := {}; END.", false},
+	{"VAR s: SET OF 0; BEGIN s := {}; END.", false},
+	{"VAR s: SET OF x..31; BEGIN s := {}; END.", false},
+	{"VAR s: SET OF 0..31, x; BEGIN IF x IN THEN x := 1; END.", false},
+	{"MODULE calc; EXPORT gcd, x; VAR x; PROCEDURE gcd; x := 1;.", true},
+	{"MODULE calc; IMPORT util; EXPORT x; VAR x;.", true},
+	{"MODULE calc; CONST c = 3;.", true},
+	{"MODULE calc; VAR x; BEGIN x := 1; END.", false},
+	{"MODULE calc; EXPORT; VAR x;.", false},
+	{"MODULE; VAR x;.", false},
+	{"MODULE calc VAR x;.", false},
+	{"IMPORT calc, util; VAR x; CALL gcd.", true},
+	{"IMPORT calc; EXPORT x; VAR x; CALL gcd.", false},
+	{"IMPORT; VAR x; CALL gcd.", false},
+	{"VAR x; IMPORT calc; CALL gcd.", false},
+	{"VAR x; PROCEDURE p; VAR a; STATIC n, r: REAL; n := n + 1; CALL p.", true},
+	{"PROCEDURE p; OWN n; n := n + 1; CALL p.", true},
+	{"MODULE m; VAR x; STATIC y;.", true},
+	{"PROCEDURE p; STATIC n; VAR a; n := n + 1; CALL p.", false},
+	{"PROCEDURE p; STATIC; n := n + 1; CALL p.", false},
+	{"PROCEDURE p; STATIC n: ; n := n + 1; CALL p.", false},
+	{"VAR x; TRY x := 1; CALL p; EXCEPT 1: x := 2; e: BEGIN x := 3; END; END.", true},
+	{"VAR x; BEGIN TRY RAISE 3; EXCEPT 3: ! 3; END; RAISE x + 1; END.", true},
+	{"VAR x; TRY x := 1; EXCEPT END.", false},
+	{"VAR x; TRY x := 1 EXCEPT 1: x := 2; END.", false},
+	{"VAR x; TRY x := 1; EXCEPT 1 x := 2; END.", false},
+	{"VAR x; TRY x := 1; EXCEPT 1: x := 2 END.", false},
+	{"VAR x; TRY x := 1; END.", false},
+	{"VAR x; RAISE.", false},
+	{"VAR x; COROUTINE g; YIELD 1; BEGIN x := RESUME g; ! RESUME g + 1; END.", true},
+	{"COROUTINE g; VAR i; PROCEDURE p; YIELD i; WHILE 1 = 1 DO CALL p; ! RESUME g.", true},
+	{"PROCEDURE p; ! 1; COROUTINE g; YIELD 2; PROCEDURE q; ! RESUME g; CALL q.", true},
+	{"COROUTINE g YIELD 1; ! RESUME g.", false},
+	{"COROUTINE g; YIELD; ! RESUME g.", false},
+	{"COROUTINE g; YIELD 1; ! RESUME 3.", false},
+	{"COROUTINE g; YIELD 1; ! RESUME.", false},
+	{"VAR s: SEMAPHORE; COBEGIN CALL p; CALL q COEND.", true},
+	{"VAR s: SEMAPHORE; COBEGIN CALL p; CALL q; COEND.", true},
+	{"VAR s: SEMAPHORE; BEGIN s := 1; WAIT(s); SIGNAL(s); COBEGIN CALL p COEND; END.", true},
+	{"COBEGIN COEND.", false},
+	{"VAR x; PROCEDURE p; BEGIN IF x > 1 THEN HALT(x - 1); HALT; END; BEGIN CALL p; HALT(0); END.", true},
+	{"IF 1 = 1 THEN HALT.", true},
+	{"HALT().", false},
+	{"HALT(1.", false},
+	{"HALT 1.", false},
+	{"VAR f: FILE, x; BEGIN OPEN(f, \"a.txt\"); READ(f, x, x); CLOSE(f); END.", true},
+	{"VAR f: FILE; BEGIN OPEN(f, \"a.txt\", 1); WRITELN(f, 1:3, \"b\"); CLOSE(f); END.", true},
+	{"VAR f: FILE; BEGIN READ(f); WRITELN(f); END.", true},
+	{"VAR f: FILE; OPEN(f).", false},
+	{"VAR f: FILE; OPEN(f, a).", false},
+	{"VAR f: FILE; OPEN(\"a.txt\").", false},
+	{"VAR f: FILE; READ(f, 1).", false},
+	{"VAR f: FILE; CLOSE(f, 1).", false},
+	{"VAR x; BEGIN ASM \"lw $t0, {x}\" \"addu $t0, $t0, 1\" \"sw $t0, {x}\" END; END.", true},
+	{"ASM \"nop\" END.", true},
+	{"ASM END.", false},
+	{"ASM \"nop\".", false},
+	{"VAR x; ASM \"lw $t0, {x\" END.", false},
+	{"VAR x; ASM \"lw $t0, x}\" END.", false},
+	{"VAR x; ASM \"lw $t0, {}\" END.", false},
+	{"VAR x; ASM \"lw $t0, {1x}\" END.", false},
+	{"VAR x, y; ASM \"lw {x}, {y}\" END.", false},
+	{"VAR x; EXTERNAL PROCEDURE max(a, b); EXTERNAL PROCEDURE tick; BEGIN x := max(x, 1) + 2; CALL tick; CALL tick(); CALL max(x, x * 2); END.", true},
+	{"EXTERNAL PROCEDURE f(a); PROCEDURE p; ! f(1); CALL p.", true},
+	{"VAR x; EXTERNAL PROCEDURE f(a); x := f().", true},
+	{"EXTERNAL f(a); CALL f(1).", false},
+	{"EXTERNAL PROCEDURE f(a) CALL f(1).", false},
+	{"EXTERNAL PROCEDURE f(); CALL f.", false},
+	{"EXTERNAL PROCEDURE f(1); CALL f(1).", false},
+	{"EXTERNAL PROCEDURE f(a); CALL f(1.", false},
+	{"EXTERNAL PROCEDURE f(a); CALL f(1,).", false},
+	{"COBEGIN CALL p CALL q COEND.", false},
+	{"COBEGIN p; q COEND.", false},
+	{"COBEGIN CALL p; ! 1 COEND.", false},
+	{"VAR s: SEMAPHORE; WAIT s.", false},
+	{"VAR s: SEMAPHORE; SIGNAL(1).", false},
+	{"VAR s: SET OF 0..9; s := [1, 3..5] + [].", true},
+	{"VAR s: SET OF 0..9; s := [1, 3..5}.", false},
+	{"VAR s: SET OF 0..9; s := {1].", false},
+	{"VAR x; (* a *) x := 1 (* b *).", true},
+	{"VAR x; (* a x := 1.", false},
+	{"CONST m = 0x7FFF_FFFF; VAR x; x := 0b1010 + 1_000.", true},
+	{"VAR x; x := 2147483648.", false},
+	{"TYPE Index = INTEGER; Vec = ARRAY 10 OF Index; VAR v: Vec, m: ARRAY 2 OF Vec; BEGIN v[1] := 2; m[0][v[1]] += v[1] * 2; INC(v[0]); END.", true},
+	{"TYPE C = (Red, Green); D = C; T = SET OF 0..3; VAR x: D; x := Red.", true},
+	{"VAR v: ARRAY OF INTEGER; v[0] := 1.", false},
+	{"VAR v: ARRAY 3 INTEGER; v[0] := 1.", false},
+	{"VAR v: ARRAY n OF INTEGER; v[0] := 1.", false},
+	{"VAR v: ARRAY 3 OF INTEGER; v[0 := 1.", false},
+	{"VAR v: ARRAY 3 OF INTEGER; v[] := 1.", false},
+	{"TYPE T = ; VAR x; x := 1.", false},
+	{"TYPE Idx = INTEGER; VAR x: Idx; PROCEDURE p; TYPE T = INTEGER; x := 1; CALL p.", true},
+	{"TYPE T = ARRAY 2 OF INTEGER; x[0] := 1.", true},
+	{"VAR s: STRING, c: CHAR; BEGIN s := \"ab\" + s; c := s[0]; WRITELN(s, LENGTH(s), c); END.", true},
+	{"VAR s: STRING; IF s < \"b\" THEN s := \"\".", true},
+	{"VAR s: STRING, n; n := LENGTH s.", false},
+	{"VAR s: STRING; s := \"a\" \"b\".", false},
+	{"VAR x, y; BEGIN x := IF y < 3 THEN y ELSE -y; WRITELN(1 + IF ODD x THEN 1 ELSE 2 * x); END.", true},
+	{"VAR x, y; x := IF IF y = 1 THEN 2 ELSE 3 = 2 THEN IF y = 2 THEN 4 ELSE 5 ELSE 6.", true},
+	{"VAR x, y; x := IF y < 3 THEN y.", false},
+	{"VAR x, y; x := IF y < 3 y ELSE 1.", false},
+	{"VAR x, y; x := IF y THEN 1 ELSE 2.", false},
+	{"VAR x, y; x := IF y < 3 THEN ELSE 2.", false},
+	{"VAR x; IF x = 1 THEN x := 2 ELSE x := 3.", false},
 }
 
 func TestScan(t *testing.T) {
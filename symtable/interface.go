@@ -0,0 +1,99 @@
+package symtable
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// An interface file describes what a module exports so that programs importing it can be analysed
+// and generated without the source of the module. It has one line per exported symbol:
+//
+//	CONST name type value
+//	VAR name type label
+//	PROCEDURE name label numvars
+//
+// The type is the name of one of the types that can be exported (INTEGER, REAL or SET).
+
+// exportableTypes maps the names of the types that can be exported to the types.
+var exportableTypes = map[string]*Type{
+	IntegerType.Name: IntegerType,
+	RealType.Name:    RealType,
+	SetType.Name:     SetType,
+}
+
+// Exportable returns a bool representing whether or not a constant or variable of the type can be
+// written to an interface file.
+func Exportable(typ *Type) bool {
+	return typ != nil && exportableTypes[typ.Name] == typ
+}
+
+// WriteInterface writes the entries of the symbol table with the given names to w in the interface
+// file format. Names that aren't in the symbol table are skipped.
+func (s *SymbolTable) WriteInterface(w io.Writer, names []string) error {
+	for _, name := range names {
+		if value := s.Get(Key{Constant, name}); value != nil {
+			if _, err := fmt.Fprintf(w, "CONST %s %s %d\n", name, value.Type.Name,
+				value.Val); err != nil {
+				return err
+			}
+		}
+		if value := s.Get(Key{Integer, name}); value != nil {
+			if _, err := fmt.Fprintf(w, "VAR %s %s %s\n", name, value.Type.Name,
+				value.Label); err != nil {
+				return err
+			}
+		}
+		if value := s.Get(Key{Procedure, name}); value != nil {
+			if _, err := fmt.Fprintf(w, "PROCEDURE %s %s %d\n", name, value.Label,
+				value.NumVars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadInterface reads an interface file and returns a new SymbolTable with its entries.
+func ReadInterface(r io.Reader) (*SymbolTable, error) {
+	s := New()
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("Malformed interface line %q.", sc.Text())
+		}
+		switch fields[0] {
+		case "CONST":
+			typ := exportableTypes[fields[2]]
+			val, err := strconv.Atoi(fields[3])
+			if typ == nil || err != nil {
+				return nil, fmt.Errorf("Malformed interface line %q.", sc.Text())
+			}
+			s.Put(Key{Constant, fields[1]}, &Value{Val: val, Type: typ})
+		case "VAR":
+			typ := exportableTypes[fields[2]]
+			if typ == nil {
+				return nil, fmt.Errorf("Malformed interface line %q.", sc.Text())
+			}
+			s.Put(Key{Integer, fields[1]}, &Value{Label: fields[3], Type: typ})
+		case "PROCEDURE":
+			numVars, err := strconv.Atoi(fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("Malformed interface line %q.", sc.Text())
+			}
+			s.Put(Key{Procedure, fields[1]}, &Value{Label: fields[2], NumVars: numVars})
+		default:
+			return nil, fmt.Errorf("Malformed interface line %q.", sc.Text())
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
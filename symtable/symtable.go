@@ -5,24 +5,102 @@ const (
 	Constant  = iota // ex. CONST a;
 	Integer          // ex. VAR a; b := 3 + c;
 	Procedure        // ex. CALL myfunc;
+	TypeName         // ex. TYPE Colour = (Red, Green); Index = INTEGER;
+	Coroutine        // ex. x := RESUME gen;
 )
 
+// Kinds of types.
+const (
+	IntegerKind   = iota // ex. VAR a;, VAR a: INTEGER;
+	RealKind             // ex. VAR a: REAL;
+	EnumKind             // ex. TYPE Colour = (Red, Green); VAR a: Colour;
+	SetKind              // ex. VAR a: SET OF 0..31;
+	SemaphoreKind        // ex. VAR a: SEMAPHORE;
+	FileKind             // ex. VAR a: FILE;
+	ArrayKind            // ex. VAR a: ARRAY 10 OF INTEGER;
+	StringKind           // ex. VAR a: STRING;
+	CharKind             // ex. VAR a: CHAR;
+)
+
+// Type describes the type of a variable, a constant or an expression. Every enumeration has its own
+// Type and a declared name for a type is given the Type it names, so apart from arrays two types
+// are the same only if they're the same pointer. See Equivalent.
+type Type struct {
+	Kind   int      // One of the kinds of types defined by this package.
+	Name   string   // Name of the type.
+	Values []string // Names of the values of an enumeration in order.
+	Len    int      // Number of elements of an array.
+	Elem   *Type    // Type of the elements of an array.
+}
+
+// Size returns the number of words a variable of the type takes up. Arrays are a word for each word
+// of their elements and everything else is a single word.
+func (t *Type) Size() int {
+	if t == nil || t.Kind != ArrayKind {
+		return 1
+	}
+	return t.Len * t.Elem.Size()
+}
+
+// Equivalent returns a bool representing whether or not values of the two types can be used in
+// place of each other. Arrays are compared by structure: they're equivalent if they have the same
+// length and their elements are equivalent. Every other type is only equivalent to itself.
+func Equivalent(t *Type, u *Type) bool {
+	if t == u {
+		return true
+	}
+	if t == nil || u == nil || t.Kind != ArrayKind || u.Kind != ArrayKind {
+		return false
+	}
+	return t.Len == u.Len && Equivalent(t.Elem, u.Elem)
+}
+
+// IntegerType is the type of integer variables, constants and expressions.
+var IntegerType = &Type{Kind: IntegerKind, Name: "INTEGER"}
+
+// RealType is the type of real variables, constants and expressions.
+var RealType = &Type{Kind: RealKind, Name: "REAL"}
+
+// SetType is the type of set variables and expressions. Sets are held in a single word, so every
+// set is a set of integers from 0 to 31 and they all share this type.
+var SetType = &Type{Kind: SetKind, Name: "SET"}
+
+// SemaphoreType is the type of semaphore variables. A semaphore holds a count, which can only be
+// set by assigning an INTEGER to it and changed with WAIT and SIGNAL.
+var SemaphoreType = &Type{Kind: SemaphoreKind, Name: "SEMAPHORE"}
+
+// FileType is the type of file variables. A file holds the descriptor of an open file, which can
+// only be set with OPEN and CLOSE.
+var FileType = &Type{Kind: FileKind, Name: "FILE"}
+
+// StringType is the type of string variables and expressions. A string is held as the address of
+// its length, which is followed by its characters and a null terminator. Strings can't be changed,
+// so they can be shared.
+var StringType = &Type{Kind: StringKind, Name: "STRING"}
+
+// CharType is the type of the characters of a string. A character is held as its code.
+var CharType = &Type{Kind: CharKind, Name: "CHAR"}
+
 // EmtpyValue is a Value with all fields initialized to nil.
 var EmptyValue *Value = &Value{}
 
 // Key implements a key for the symbol table. Should be initialized with a tag (const defined by
 // this package) and a lexeme.
 type Key struct {
-	Tag int    // One of Constant, Integer, or Procedure.
+	Tag int    // One of Constant, Integer, Procedure, TypeName or Coroutine.
 	Lex string // Lexeme of Token.
 }
 
 // Value contains information needed by the code generation phase.
 type Value struct {
-	Label   string // Assembly label of function for code generation purposes.
-	Order   int    // The position in the stack frame of the variable (nth VAR).
-	Val     int    // For constants.
-	NumVars int    // Number of vars for procedures.
+	Label   string // Assembly label of a procedure, a coroutine or a variable in the data segment.
+	Order   int    // Position in words of the lowest address of the variable in the stack frame.
+	Val     int    // For constants. Real constants hold the bits of a single precision float.
+	NumVars int    // Number of vars for procedures and coroutines.
+	Type    *Type  // Type of variables and constants, or the type named by a TypeName.
+	// External procedures are written in assembly and called with their arguments in $a0 to $a3.
+	External bool
+	NumArgs  int // Number of arguments of an external procedure.
 }
 
 // SymbolTable implements a symbol table as a map with key Key and value *Value.
@@ -48,3 +126,12 @@ func (s *SymbolTable) Put(key Key, value *Value) {
 func (s *SymbolTable) Get(key Key) *Value {
 	return s.table[key]
 }
+
+// Keys returns the keys of every entry in the symbol table in no particular order.
+func (s *SymbolTable) Keys() []Key {
+	keys := make([]Key, 0, len(s.table))
+	for key := range s.table {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,21 @@
+.text
+.globl max
+max:
+slt $t0, $a0, $a1
+move $v0, $a0
+beq $t0, $zero, max_done
+move $v0, $a1
+max_done:
+jr $ra
+.globl sum4
+sum4:
+addu $v0, $a0, $a1
+addu $v0, $v0, $a2
+addu $v0, $v0, $a3
+jr $ra
+.globl tick
+tick:
+li $a0, 33
+li $v0, 11
+syscall
+jr $ra
@@ -3,6 +3,7 @@ package token
 
 import (
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -22,23 +23,80 @@ const (
 	Divide                    // /
 	LeftCurlyBrace            // {
 	RightCurlyBrace           // }
+	LeftBracket               // [
+	RightBracket              // ]
 	LeftParen                 // (
 	RightParen                // )
 	Exclamation               // !
 	Assignment                // :=
+	PlusAssignment            // +=
+	MinusAssignment           // -=
+	TimesAssignment           // *=
+	Colon                     // :
+	DotDot                    // ..
 	Integer                   // ex. 42
+	Real                      // ex. 3.14
 	Identifier                // ex. abc, abc123, ABC123
+	String                    // ex. "abc"
+	Array                     // ARRAY
+	Asm                       // ASM
+	Assert                    // ASSERT
 	Begin                     // BEGIN
 	Call                      // CALL
+	Case                      // CASE
+	CharType                  // CHAR
+	Close                     // CLOSE
+	Cobegin                   // COBEGIN
+	Coend                     // COEND
 	Const                     // CONST
+	Coroutine                 // COROUTINE
+	Dec                       // DEC
 	Do                        // DO
+	Downto                    // DOWNTO
+	Else                      // ELSE
 	End                       // END
+	Except                    // EXCEPT
+	Export                    // EXPORT
+	External                  // EXTERNAL
+	File                      // FILE
+	Float                     // FLOAT
+	For                       // FOR
+	Halt                      // HALT
 	If                        // IF
+	Import                    // IMPORT
+	In                        // IN
+	Inc                       // INC
+	Include                   // INCLUDE
+	IntegerType               // INTEGER
+	Length                    // LENGTH
+	Module                    // MODULE
 	Odd                       // ODD
+	Of                        // OF
+	Open                      // OPEN
+	Ord                       // ORD
+	Pred                      // PRED
 	Procedure                 // PROCEDURE
+	Raise                     // RAISE
+	Read                      // READ
+	RealType                  // REAL
+	Resume                    // RESUME
+	Semaphore                 // SEMAPHORE
+	Set                       // SET
+	Signal                    // SIGNAL
+	Static                    // STATIC, OWN
+	StringType                // STRING
+	Succ                      // SUCC
 	Then                      // THEN
+	To                        // TO
+	Trunc                     // TRUNC
+	Try                       // TRY
+	Type                      // TYPE
 	Var                       // VAR
+	Wait                      // WAIT
 	While                     // WHILE
+	Write                     // WRITE
+	Writeln                   // WRITELN
+	Yield                     // YIELD
 	Error                     // Special type for EOF and UnexpectedChar.
 )
 
@@ -55,14 +113,34 @@ var UnexpectedChar = &Token{Tag: Error, Err: errors.New("Unexpected character")}
 // Token implements a lexical token. It contains all the information needed by the compiler to
 // represent a lexical unit.
 type Token struct {
-	Tag int    // Tag. One of the constants defined in this package.
-	Val int    // Value.
-	Ln  int    // Line number.
-	Lex string // Lexeme.
-	Err error  // Error.
+	Tag  int     // Tag. One of the constants defined in this package.
+	Val  int     // Value.
+	Rval float64 // Value of a Real.
+	Ln   int     // Line number.
+	File string  // Name of the source file. Empty if the source isn't a file.
+	Lex  string  // Lexeme.
+	Err  error   // Error.
 }
 
-// New returns a new Token with the specified line number set.
-func New(ln int) *Token {
-	return &Token{Ln: ln}
+// New returns a new Token with the specified line number and file set.
+func New(ln int, file string) *Token {
+	return &Token{Ln: ln, File: file}
+}
+
+// NewError returns a new Token with the tag Error for a lexical error at the specified line number
+// and file. The error describes the problem and where it is.
+func NewError(ln int, file string, msg string) *Token {
+	tok := New(ln, file)
+	tok.Tag = Error
+	tok.Err = fmt.Errorf("Syntax error %s: %s.", tok.Pos(), msg)
+	return tok
+}
+
+// Pos returns where the Token is in the source for error messages, ex. "near line 3" or
+// "in a.sim near line 3".
+func (t *Token) Pos() string {
+	if t.File == "" {
+		return fmt.Sprintf("near line %d", t.Ln)
+	}
+	return fmt.Sprintf("in %s near line %d", t.File, t.Ln)
 }